@@ -129,7 +129,9 @@ func (a *Api) GenerateHandlers(kind string, enforceRoles, datadog, rateLimit boo
 		}
 	}
 
-	if ingest.UseRateLimit() && rateLimit {
+	// rate limits may be added by a reload, so we always add the limiter.
+	// it lets everything through for orgs without a limit.
+	if rateLimit {
 		combinedHandlers = append(combinedHandlers, IngestRateLimiter())
 	}
 
@@ -139,10 +141,11 @@ func (a *Api) GenerateHandlers(kind string, enforceRoles, datadog, rateLimit boo
 func getAuthCreds(req *http.Request) (user, password string) {
 	username, key, ok := req.BasicAuth()
 	if !ok {
-		// no basicAuth, but we also need to check for a Bearer Token
+		// no basicAuth, but we also need to check for a Bearer Token. InfluxDB
+		// clients send it with the Token scheme.
 		header := req.Header.Get("Authorization")
 		parts := strings.SplitN(header, " ", 2)
-		if len(parts) == 2 && parts[0] == "Bearer" {
+		if len(parts) == 2 && (parts[0] == "Bearer" || parts[0] == "Token") {
 			keyParts := strings.SplitN(parts[1], ":", 2)
 			if len(keyParts) < 2 {
 				key = keyParts[0]
@@ -196,6 +199,10 @@ func (a *Api) DDAuth() macaron.Handler {
 		var username string
 
 		header := ctx.Req.Header.Get("Dd-Api-Key")
+		if header == "" {
+			// older agents send the key of intake payloads as a query parameter
+			header = ctx.Query("api_key")
+		}
 		parts := strings.SplitN(header, ":", 2)
 		if len(parts) == 1 {
 			key = parts[0]
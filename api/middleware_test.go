@@ -35,6 +35,13 @@ func TestGetAuthCreds(t *testing.T) {
 		c.So(user, ShouldEqual, "4")
 		c.So(pass, ShouldEqual, "abcdefg")
 	})
+	Convey("When authenticating with an influx token", t, func(c C) {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Add("Authorization", "Token 4:abcdefg")
+		user, pass := getAuthCreds(req)
+		c.So(user, ShouldEqual, "4")
+		c.So(pass, ShouldEqual, "abcdefg")
+	})
 	Convey("When authenticating with basicAuth and bearer", t, func(c C) {
 		req, _ := http.NewRequest("GET", "/", nil)
 		req.Header.Add("Authorization", "Bearer 4:abcdefg")
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/raintank/tsdb-gw/api/models"
+	"github.com/raintank/tsdb-gw/ingest"
+	"gopkg.in/macaron.v1"
+)
+
+// RateLimitSync returns a handler that serves the shared rate limit counters
+// to the other gateway replicas. Only admins may report usage.
+func (a *Api) RateLimitSync(backend ingest.RateLimitBackend) []macaron.Handler {
+	return []macaron.Handler{
+		a.Auth(),
+		RequireAdmin(),
+		func(ctx *models.Context) {
+			if ctx.Req.Request.Body == nil {
+				ctx.JSON(400, "no data included in request.")
+				return
+			}
+			defer ctx.Req.Request.Body.Close()
+
+			var report ingest.RateLimitReport
+			if err := json.NewDecoder(ctx.Req.Request.Body).Decode(&report); err != nil {
+				ctx.JSON(400, err.Error())
+				return
+			}
+			if report.Replica == "" {
+				ctx.JSON(400, "replica is required")
+				return
+			}
+			usage, err := backend.Report(report)
+			if err != nil {
+				ctx.JSON(500, err.Error())
+				return
+			}
+			ctx.JSON(200, usage)
+		},
+	}
+}
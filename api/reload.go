@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/raintank/tsdb-gw/api/models"
+	"github.com/raintank/tsdb-gw/util"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/macaron.v1"
+)
+
+// Reload returns a handler that reloads the configuration and reports
+// the outcome per part of the configuration. Only admins may reload.
+func (a *Api) Reload(reloader *util.Reloader) []macaron.Handler {
+	return []macaron.Handler{
+		a.Auth(),
+		RequireAdmin(),
+		func(ctx *models.Context) {
+			log.Infof("reload: requested through the api by user %d", ctx.ID)
+			results, ok := reloader.Reload()
+			if !ok {
+				ctx.JSON(500, results)
+				return
+			}
+			ctx.JSON(200, results)
+		},
+	}
+}
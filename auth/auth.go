@@ -3,6 +3,7 @@ package auth
 import (
 	"errors"
 	"flag"
+	"sync"
 
 	"github.com/raintank/tsdb-gw/auth/gcom"
 	log "github.com/sirupsen/logrus"
@@ -39,6 +40,33 @@ type AuthPlugin interface {
 	Stop()
 }
 
+// AuthPublisher validates the credentials with the plugin. With
+// requirePublisher, the user must also have a role that can publish.
+// The api checks the role in its middleware, the other inputs use this.
+func AuthPublisher(plugin AuthPlugin, username, password string, requirePublisher bool) (*User, error) {
+	user, err := plugin.Auth(username, password)
+	if err != nil {
+		return nil, err
+	}
+	if requirePublisher && !user.Role.IsPublisher() {
+		return nil, ErrInvalidRole
+	}
+	return user, nil
+}
+
+var (
+	fileAuth     *FileAuth
+	fileAuthOnce sync.Once
+)
+
+// ReloadFileAuth reloads the auth file if the file auth plugin is in use
+func ReloadFileAuth() error {
+	if fileAuth == nil {
+		return nil
+	}
+	return fileAuth.Reload()
+}
+
 func GetAuthPlugin(name string) AuthPlugin {
 	log.Debugf("initializing auth plugin %s", name)
 	switch name {
@@ -47,7 +75,12 @@ func GetAuthPlugin(name string) AuthPlugin {
 	case "grafana-instance":
 		return NewGrafanaComInstanceAuth()
 	case "file":
-		return NewFileAuth()
+		// the api and the carbon input share the file auth, so that
+		// both see the same keys after a reload
+		fileAuthOnce.Do(func() {
+			fileAuth = NewFileAuth()
+		})
+		return fileAuth
 	default:
 		log.Fatalf("invalid auth plugin specified, %s", name)
 	}
@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"path"
+	"sync"
 
 	"github.com/raintank/tsdb-gw/auth/gcom"
 
@@ -27,6 +30,7 @@ instances = 1,2,4
 -------------------
 */
 type FileAuth struct {
+	sync.RWMutex
 	keys        map[string]*User //map auth key to orgId
 	instanceMap map[string]int
 	filePath    string
@@ -41,14 +45,34 @@ func init() {
 func NewFileAuth() *FileAuth {
 	log.Infof("auth.file: loading carbon auth file from %s", filePath)
 	a := &FileAuth{
-		keys:        make(map[string]*User),
-		instanceMap: make(map[string]int),
-		filePath:    path.Clean(filePath),
+		filePath: path.Clean(filePath),
 	}
+	if err := a.Reload(); err != nil {
+		log.Fatalf("auth.file: %s", err)
+	}
+	return a
+}
+
+// Reload re-reads the auth file. If it can't be loaded, the current keys are kept.
+func (a *FileAuth) Reload() error {
+	keys, instanceMap, err := loadAuthFile(a.filePath)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	a.keys = keys
+	a.instanceMap = instanceMap
+	a.Unlock()
+	return nil
+}
+
+func loadAuthFile(filePath string) (map[string]*User, map[string]int, error) {
+	keys := make(map[string]*User)
+	instanceMap := make(map[string]int)
 
 	conf, err := ini.Load(filePath)
 	if err != nil {
-		log.Fatalf("auth.file: could not load auth file: %v", filePath)
+		return nil, nil, fmt.Errorf("could not load auth file %s: %s", filePath, err)
 	}
 
 	for _, section := range conf.Sections() {
@@ -77,7 +101,7 @@ func NewFileAuth() *FileAuth {
 			isAdmin = isAdminKey.MustBool(false)
 		}
 
-		a.keys[section.Name()] = &User{
+		keys[section.Name()] = &User{
 			ID:      orgID,
 			IsAdmin: isAdmin,
 			Role:    gcom.ROLE_ADMIN,
@@ -94,21 +118,23 @@ func NewFileAuth() *FileAuth {
 		}
 		instances := instanceKey.Strings(",")
 		for _, i := range instances {
-			a.instanceMap[i] = orgID
+			instanceMap[i] = orgID
 		}
 	}
-	if len(a.keys) == 0 {
-		log.Fatalf("auth.file: no auth credentials found in auth-file.")
+	if len(keys) == 0 {
+		return nil, nil, errors.New("no auth credentials found in auth-file")
 	}
 
-	return a
+	return keys, instanceMap, nil
 }
 
 func (a *FileAuth) Auth(instanceID, password string) (*User, error) {
 	if password == AdminKey {
 		return AdminUser, nil
 	}
+	a.RLock()
 	user, ok := a.keys[password]
+	a.RUnlock()
 	if !ok {
 		log.Debugf("auth.file: key not found: %v", password)
 		return nil, ErrInvalidCredentials
@@ -119,7 +145,9 @@ func (a *FileAuth) Auth(instanceID, password string) (*User, error) {
 	}
 
 	if instanceID != "api_key" {
+		a.RLock()
 		ID, ok := a.instanceMap[instanceID]
+		a.RUnlock()
 		if !ok {
 			log.Debugf("auth.file: instanceID %q not found", ID)
 			return nil, ErrInvalidInstanceID
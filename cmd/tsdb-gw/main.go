@@ -16,15 +16,19 @@ import (
 	"github.com/grafana/metrictank/stats"
 	"github.com/raintank/dur"
 	"github.com/raintank/tsdb-gw/api"
+	"github.com/raintank/tsdb-gw/auth"
 	"github.com/raintank/tsdb-gw/ingest"
 	"github.com/raintank/tsdb-gw/ingest/carbon"
 	"github.com/raintank/tsdb-gw/ingest/datadog"
+	"github.com/raintank/tsdb-gw/ingest/statsd"
+	"github.com/raintank/tsdb-gw/persister/persist"
 	"github.com/raintank/tsdb-gw/publish"
 	"github.com/raintank/tsdb-gw/publish/kafka"
 	"github.com/raintank/tsdb-gw/query/graphite"
 	"github.com/raintank/tsdb-gw/query/metrictank"
 	"github.com/raintank/tsdb-gw/util"
 	log "github.com/sirupsen/logrus"
+	"gopkg.in/ini.v1"
 )
 
 var (
@@ -41,6 +45,7 @@ var (
 	graphiteURL   = flag.String("graphite-url", "http://localhost:8080", "graphite-api address")
 	metrictankURL = flag.String("metrictank-url", "http://localhost:6060", "metrictank address")
 	importerURL   = flag.String("importer-url", "", "mt-whisper-importer-writer address")
+	persisterURL  = flag.String("persister-url", "", "persister-gw address, to store the host metadata sent by datadog agents. Disabled when empty")
 
 	// stats and tracing
 	statsEnabled    = flag.Bool("stats-enabled", false, "enable sending graphite messages for instrumentation")
@@ -54,7 +59,32 @@ var (
 
 	// limitations
 	timerangeLimit = flag.String("timerange-limit", "", "define maximum timerange to serve queries for")
-	rateLimits     = flag.String("rate-limits", "", "define rate limits in the format \"<orgId>:<limit>;<orgId>:<limit>\" where <limit> is the number of datapoints per second")
+	rateLimits     = flag.String("rate-limits", "", "define rate limits in the format \"<orgId>:<limit>;<orgId>:<limit>\" where <limit> is the number of datapoints per second or the name of a tier")
+	rateLimitTiers = flag.String("rate-limit-tiers", "", "define named rate limits in the format \"<tier>:<limit>;<tier>:<limit>\" that can be used in rate-limits and rate-limit-default")
+	rateLimitDef   = flag.String("rate-limit-default", "", "rate limit for orgs not listed in rate-limits, as the number of datapoints per second or the name of a tier. empty means unlimited")
+	rateLimitIdle  = flag.Duration("rate-limit-idle-timeout", 10*time.Minute, "remove the rate limiter of an org after it has not sent data for this long. It is recreated when the org sends data again")
+
+	seriesLimits      = flag.String("series-limits", "", "define active series limits in the format \"<orgId>:<limit>;<orgId>:<limit>\". Samples of new series of an org over its limit are rejected")
+	seriesLimitDef    = flag.String("series-limit-default", "", "active series limit for orgs not listed in series-limits. empty means unlimited")
+	seriesLimitWindow = flag.Duration("series-limit-window", time.Hour, "the active series are wiped once per this window, so a series stays active for up to this long after it last received data")
+
+	// validation, applied to all ingest protocols
+	validateMaxNameLength  = flag.Int("validation-max-name-length", 0, "reject metrics with longer names. 0 means unlimited")
+	validateMaxTags        = flag.Int("validation-max-tags", 0, "reject metrics with more tags. 0 means unlimited")
+	validateMaxTagKeyLen   = flag.Int("validation-max-tag-key-length", 0, "reject metrics with longer tag keys. 0 means unlimited")
+	validateMaxTagValueLen = flag.Int("validation-max-tag-value-length", 0, "reject metrics with longer tag values. 0 means unlimited")
+	validateAllowedChars   = flag.String("validation-allowed-chars", "", "characters allowed in metric names and tag keys, as the contents of a regular expression character class, e.g. \"a-zA-Z0-9_.-\". empty allows all")
+	validateReservedTags   = flag.String("validation-reserved-tags", "", "comma separated list of tag keys that clients may not set")
+	validateMaxAge         = flag.Duration("validation-max-age", 0, "reject metrics with timestamps further in the past. 0 means unlimited")
+	validateMaxFuture      = flag.Duration("validation-max-future", 0, "reject metrics with timestamps further in the future. 0 means unlimited")
+	validateOutOfRange     = flag.String("validation-out-of-range", "reject", "what to do with metrics with timestamps out of the range set by validation-max-age and validation-max-future: reject them, or clamp their timestamp to the range. (reject|clamp)")
+
+	// cluster-wide rate limiting
+	rateLimitClusterServe    = flag.Bool("rate-limit-cluster-serve", false, "serve the rate limit counters shared by the gateway replicas at /admin/rate-limit/sync, and split the rate limits with the replicas using them")
+	rateLimitClusterURL      = flag.String("rate-limit-cluster-url", "", "url of the /admin/rate-limit/sync endpoint of the gateway serving the shared rate limit counters. When set, the rate limits are split with the other replicas using it")
+	rateLimitClusterKey      = flag.String("rate-limit-cluster-key", "", "admin api key used to authenticate to rate-limit-cluster-url")
+	rateLimitClusterReplica  = flag.String("rate-limit-cluster-replica", "", "name of this replica in the rate limit cluster. Must be unique, defaults to the hostname")
+	rateLimitClusterInterval = flag.Duration("rate-limit-cluster-interval", time.Second, "how often to sync the rate limit usage with the other replicas")
 
 	metricsAddr = flag.String("metrics-addr", ":8001", "http service address for the /metrics endpoint")
 )
@@ -121,28 +151,164 @@ func main() {
 			log.Fatalf(err.Error())
 		}
 	}
+	if err := datadog.Init(); err != nil {
+		log.Fatal(err)
+	}
+	if len(*persisterURL) > 0 {
+		if err := persist.Init(*persisterURL); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	if err := ingest.ConfigureRateLimits(*rateLimits); err != nil {
+	if err := ingest.ConfigureTieredRateLimits(*rateLimits, *rateLimitTiers, *rateLimitDef); err != nil {
 		log.Fatalf(err.Error())
 	}
+	go ingest.EvictIdleRateLimiters(*rateLimitIdle)
+
+	validation := ingest.ValidationConfig{
+		MaxNameLength:     *validateMaxNameLength,
+		MaxTags:           *validateMaxTags,
+		MaxTagKeyLength:   *validateMaxTagKeyLen,
+		MaxTagValueLength: *validateMaxTagValueLen,
+		AllowedChars:      *validateAllowedChars,
+		MaxAge:            *validateMaxAge,
+		MaxFutureSkew:     *validateMaxFuture,
+	}
+	switch *validateOutOfRange {
+	case "reject":
+	case "clamp":
+		validation.ClampTimestamps = true
+	default:
+		log.Fatalf("invalid validation-out-of-range %q, must be reject or clamp", *validateOutOfRange)
+	}
+	if len(*validateReservedTags) > 0 {
+		validation.ReservedTags = strings.Split(*validateReservedTags, ",")
+	}
+	if err := ingest.ConfigureValidation(validation); err != nil {
+		log.Fatal(err)
+	}
+
+	ingest.InitSeriesLimits(*seriesLimitWindow)
+	if err := ingest.ConfigureSeriesLimits(*seriesLimits, *seriesLimitDef); err != nil {
+		log.Fatal(err)
+	}
+
+	var rateLimitBackend *ingest.MemoryRateLimitBackend
+	if *rateLimitClusterServe || len(*rateLimitClusterURL) > 0 {
+		replica := *rateLimitClusterReplica
+		if replica == "" {
+			replica, _ = os.Hostname()
+		}
+		var backend ingest.RateLimitBackend
+		if *rateLimitClusterServe {
+			if len(*rateLimitClusterURL) > 0 {
+				log.Fatalf("rate-limit-cluster-serve and rate-limit-cluster-url are mutually exclusive")
+			}
+			rateLimitBackend = ingest.NewMemoryRateLimitBackend()
+			backend = rateLimitBackend
+		} else {
+			backend = ingest.NewHTTPRateLimitBackend(*rateLimitClusterURL, *rateLimitClusterKey, *rateLimitClusterInterval)
+		}
+		go ingest.SyncRateLimits(backend, replica, *rateLimitClusterInterval)
+	}
 
 	inputs := make([]Stoppable, 0)
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 
 	api := api.New(*authPlugin, app)
-	initRoutes(api, *enforceRoles)
+
+	reloader := util.NewReloader()
+	reloader.Add("rate-limits", func() error {
+		return reloadRateLimits(path)
+	})
+	reloader.Add("series-limits", func() error {
+		return reloadSeriesLimits(path)
+	})
+	reloader.Add("auth-file", auth.ReloadFileAuth)
+	if publisher != nil {
+		reloader.Add("schemas", publisher.ReloadSchemas)
+		reloader.Add("kafka-routing", publisher.ReloadRouting)
+	}
+	reloader.HandleSignals()
+
+	initRoutes(api, *enforceRoles, reloader, rateLimitBackend)
 
 	ms := util.NewMetricsServer(*metricsAddr)
 
 	log.Infof("Starting %v ...", app)
 	done := make(chan struct{})
-	inputs = append(inputs, api.Start(), carbon.InitCarbon(*enforceRoles), ms)
+	inputs = append(inputs, api.Start(), carbon.InitCarbon(*enforceRoles), statsd.InitStatsd(*enforceRoles), ms)
 	go handleShutdown(done, interrupt, inputs)
 	log.Infof("%v Started", app)
 	<-done
 }
 
+type reloadableSetting struct {
+	name  string
+	value *string
+}
+
+// reloadSettings re-reads the settings from the config file and applies them.
+// Like at startup, values given on the command line or in the environment
+// take precedence over the config file, so those stay as they are.
+// The settings are only updated if apply succeeds.
+func reloadSettings(confPath string, settings []reloadableSetting, apply func(values []string) error) error {
+	setOnCmdline := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setOnCmdline[f.Name] = true
+	})
+
+	values := make([]string, len(settings))
+	var conf *ini.File
+	for i, setting := range settings {
+		values[i] = *setting.value
+		envKey := "GW_" + strings.ToUpper(strings.Replace(setting.name, "-", "_", -1))
+		if _, setInEnv := os.LookupEnv(envKey); confPath == "" || setInEnv || setOnCmdline[setting.name] {
+			continue
+		}
+		if conf == nil {
+			var err error
+			conf, err = ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, confPath)
+			if err != nil {
+				return err
+			}
+		}
+		values[i] = conf.Section("").Key(setting.name).String()
+	}
+
+	if err := apply(values); err != nil {
+		return err
+	}
+	for i, setting := range settings {
+		*setting.value = values[i]
+	}
+	return nil
+}
+
+// reloadRateLimits re-reads the rate limit settings from the config file
+func reloadRateLimits(confPath string) error {
+	settings := []reloadableSetting{
+		{"rate-limits", rateLimits},
+		{"rate-limit-tiers", rateLimitTiers},
+		{"rate-limit-default", rateLimitDef},
+	}
+	return reloadSettings(confPath, settings, func(values []string) error {
+		return ingest.ConfigureTieredRateLimits(values[0], values[1], values[2])
+	})
+}
+
+// reloadSeriesLimits re-reads the series limit settings from the config file
+func reloadSeriesLimits(confPath string) error {
+	settings := []reloadableSetting{
+		{"series-limits", seriesLimits},
+		{"series-limit-default", seriesLimitDef},
+	}
+	return reloadSettings(confPath, settings, func(values []string) error {
+		return ingest.ConfigureSeriesLimits(values[0], values[1])
+	})
+}
+
 type Stoppable interface {
 	Stop()
 }
@@ -176,10 +342,15 @@ func handleShutdown(done chan struct{}, interrupt chan os.Signal, inputs []Stopp
 	close(done)
 }
 
-func initRoutes(a *api.Api, enforceRoles bool) {
+func initRoutes(a *api.Api, enforceRoles bool, reloader *util.Reloader, rateLimitBackend *ingest.MemoryRateLimitBackend) {
 	a.Router.Use(api.RequestStats())
+	a.Router.Post("/admin/reload", a.Reload(reloader)...)
+	if rateLimitBackend != nil {
+		a.Router.Post("/admin/rate-limit/sync", a.RateLimitSync(rateLimitBackend)...)
+	}
 	a.Router.Get("/metrics/index.json", a.GenerateHandlers("read", enforceRoles, false, false, metrictank.MetrictankProxy("/metrics/index.json"))...)
 	a.Router.Get("/graphite/metrics/index.json", a.GenerateHandlers("read", enforceRoles, false, false, metrictank.MetrictankProxy("/metrics/index.json"))...)
+	a.Router.Post("/prometheus/read", a.GenerateHandlers("read", enforceRoles, false, false, metrictank.PrometheusRead)...)
 	a.Router.Any("/prometheus/*", a.GenerateHandlers("read", enforceRoles, false, false, metrictank.PrometheusProxy)...)
 	if len(*timerangeLimit) > 0 {
 		a.Router.Any("/graphite/*", a.GenerateHandlers("read", enforceRoles, false, false, api.CaptureBody, binding.Bind(graphite.FromTo{}), a.PromStats("graphite"), graphite.GraphiteProxy)...)
@@ -187,9 +358,15 @@ func initRoutes(a *api.Api, enforceRoles bool) {
 		a.Router.Any("/graphite/*", a.GenerateHandlers("read", enforceRoles, false, false, a.PromStats("graphite"), graphite.GraphiteProxy)...)
 	}
 	a.Router.Post("/metrics", a.GenerateHandlers("write", enforceRoles, false, true, ingest.Metrics)...)
-	a.Router.Post("/datadog/api/v1/series", a.GenerateHandlers("write", enforceRoles, true, false, datadog.DataDogSeries)...)
-	a.Router.Post("/opentsdb/api/put", a.GenerateHandlers("write", enforceRoles, false, false, ingest.OpenTSDBWrite)...)
-	a.Router.Any("/prometheus/write", a.GenerateHandlers("write", enforceRoles, false, false, ingest.PrometheusMTWrite)...)
+	a.Router.Post("/datadog/api/v1/series", a.GenerateHandlers("write", enforceRoles, true, true, datadog.DataDogSeries)...)
+	a.Router.Post("/datadog/api/v2/series", a.GenerateHandlers("write", enforceRoles, true, true, datadog.DataDogSeriesV2)...)
+	a.Router.Post("/datadog/api/v1/distribution_points", a.GenerateHandlers("write", enforceRoles, true, true, datadog.DataDogDistributionPoints)...)
+	a.Router.Post("/datadog/api/v1/check_run", a.GenerateHandlers("write", enforceRoles, true, true, datadog.DataDogCheck)...)
+	a.Router.Post("/datadog/intake/", a.GenerateHandlers("write", enforceRoles, true, false, datadog.DataDogIntake)...)
+	a.Router.Post("/opentsdb/api/put", a.GenerateHandlers("write", enforceRoles, false, true, ingest.OpenTSDBWrite)...)
+	a.Router.Post("/influx/write", a.GenerateHandlers("write", enforceRoles, false, true, ingest.InfluxWriteV1)...)
+	a.Router.Post("/influx/api/v2/write", a.GenerateHandlers("write", enforceRoles, false, true, ingest.InfluxWriteV2)...)
+	a.Router.Any("/prometheus/write", a.GenerateHandlers("write", enforceRoles, false, true, ingest.PrometheusMTWrite)...)
 	a.Router.Post("/metrics/delete", a.GenerateHandlers("write", enforceRoles, false, false, metrictank.MetrictankProxy("/metrics/delete"))...)
 	a.Router.Post("/tags/delSeries", a.GenerateHandlers("write", enforceRoles, false, false, metrictank.MetrictankProxy("/tags/delSeries"))...)
 
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -13,18 +14,21 @@ import (
 	"github.com/graphite-ng/carbon-relay-ng/input"
 	m20 "github.com/metrics20/go-metrics20/carbon20"
 	"github.com/raintank/tsdb-gw/auth"
+	"github.com/raintank/tsdb-gw/ingest"
 	"github.com/raintank/tsdb-gw/publish"
 	"github.com/raintank/tsdb-gw/util"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	metricsReceived          = stats.NewCounterRate32("metrics.carbon.received")
-	metricsValid             = stats.NewCounterRate32("metrics.carbon.valid")
-	metricsRejected          = stats.NewCounterRate32("metrics.carbon.rejected")
-	metricsFailed            = stats.NewCounterRate32("metrics.carbon.failed")
-	metricsDroppedBufferFull = stats.NewCounterRate32("metrics.carbon.dropped_buffer_full")
-	metricsDroppedAuthFail   = stats.NewCounterRate32("metrics.carbon.dropped_auth_fail")
+	metricsReceived           = stats.NewCounterRate32("metrics.carbon.received")
+	metricsValid              = stats.NewCounterRate32("metrics.carbon.valid")
+	metricsRejected           = stats.NewCounterRate32("metrics.carbon.rejected")
+	metricsFailed             = stats.NewCounterRate32("metrics.carbon.failed")
+	metricsDroppedBufferFull  = stats.NewCounterRate32("metrics.carbon.dropped_buffer_full")
+	metricsDroppedAuthFail    = stats.NewCounterRate32("metrics.carbon.dropped_auth_fail")
+	metricsDroppedRateLimit   = stats.NewCounterRate32("metrics.carbon.dropped_rate_limit")
+	metricsDroppedSeriesLimit = stats.NewCounterRate32("metrics.carbon.dropped_series_limit")
 
 	metricsTSLock    = &sync.Mutex{}
 	metricsTimestamp = make(map[int]*stats.Range32)
@@ -33,11 +37,14 @@ var (
 
 	Enabled           bool
 	addr              string
+	pickleEnabled     bool
+	pickleAddr        string
 	concurrency       int
 	bufferSize        int
 	flushInterval     time.Duration
 	nonBlockingBuffer bool
 	authPlugin        string
+	connAuth          bool
 
 	metricPool = util.NewMetricDataPool()
 )
@@ -45,11 +52,14 @@ var (
 func init() {
 	flag.BoolVar(&Enabled, "carbon-enabled", false, "enable carbon input")
 	flag.StringVar(&addr, "carbon-addr", "0.0.0.0:2003", "listen address for carbon input")
+	flag.BoolVar(&pickleEnabled, "carbon-pickle-enabled", false, "enable carbon pickle input")
+	flag.StringVar(&pickleAddr, "carbon-pickle-addr", "0.0.0.0:2004", "listen address for carbon pickle input")
 	flag.StringVar(&authPlugin, "carbon-auth-plugin", "file", "auth plugin to use. (grafana|file)")
 	flag.DurationVar(&flushInterval, "carbon-flush-interval", time.Second, "maximum time between flushs to kafka")
 	flag.IntVar(&concurrency, "carbon-concurrency", 1, "number of goroutines for handling metrics")
 	flag.IntVar(&bufferSize, "carbon-buffer-size", 100000, "number of metrics to hold in an input buffer. Once this buffer fills metrics will be dropped")
 	flag.BoolVar(&nonBlockingBuffer, "carbon-non-blocking-buffer", false, "dont block trying to write to the input buffer, just drop metrics.")
+	flag.BoolVar(&connAuth, "carbon-conn-auth", false, "require clients to authenticate once per connection with a first line of \"AUTH <instance> <key>\" instead of prefixing every metric name with an api key")
 }
 
 func getMetricsTimestampStat(org int) *stats.Range32 {
@@ -63,31 +73,69 @@ func getMetricsTimestampStat(org int) *stats.Range32 {
 	return metricTimestamp
 }
 
+// line is a single carbon line waiting to be flushed
+type line struct {
+	buf  []byte
+	user *auth.User
+	udp  bool // received over UDP, see udpMetricsRejected
+}
+
+// reject counts the line as rejected by the given counter, and by
+// udpMetricsRejected too when it was received over UDP.
+func (l line) reject(counter *stats.CounterRate32) {
+	counter.Inc()
+	if l.udp {
+		udpMetricsRejected.Inc()
+	}
+}
+
 type Carbon struct {
 	listener         *input.Listener
+	pickleListener   *input.Listener
+	tlsListener      *tlsListener
+	udpListener      *udpListener
 	schemas          *conf.Schemas
-	buf              chan []byte
+	buf              chan line
 	flushWg          sync.WaitGroup
 	authPlugin       auth.AuthPlugin
 	requirePublisher bool
 }
 
 func InitCarbon(requirePublisher bool) *Carbon {
-	if !Enabled {
+	if !anyEnabled() {
 		return &Carbon{}
 	}
 
 	c := &Carbon{
 		authPlugin:       auth.GetAuthPlugin(authPlugin),
 		requirePublisher: requirePublisher,
-		buf:              make(chan []byte, bufferSize),
+		buf:              make(chan line, bufferSize),
 	}
 	// note that we use our Carbon ingest plugin directly as Dispatcher
-	c.listener = input.NewListener(addr, 2*time.Minute, input.NewPlain(c))
-	c.listener.HandleConn = handleConn
-	err := c.listener.Start()
-	if err != nil {
-		log.Fatal(err)
+	// for all handlers. The pickle handler turns each decoded item into a
+	// plain line, so all listeners share the same pipeline.
+	if Enabled {
+		c.listener = startListener(addr, c.handler(newPlain), c.handleUDPData)
+	}
+	if pickleEnabled {
+		c.pickleListener = startListener(pickleAddr, c.handler(newPickle), nil)
+	}
+	if tlsEnabled {
+		tlsConfig, err := getTLSConfig()
+		if err != nil {
+			log.Fatalf("failed to configure carbon TLS listener: %s", err)
+		}
+		c.tlsListener, err = newTLSListener(tlsAddr, tlsConfig, c.handler(newPlain))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if udpEnabled {
+		var err error
+		c.udpListener, err = newUDPListener(c, udpAddr, udpReadBuffer)
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 	c.flushWg.Add(concurrency)
 	for i := 0; i < concurrency; i++ {
@@ -96,41 +144,109 @@ func InitCarbon(requirePublisher bool) *Carbon {
 	return c
 }
 
+func anyEnabled() bool {
+	return Enabled || pickleEnabled || tlsEnabled || udpEnabled
+}
+
+func newPlain(d input.Dispatcher) input.Handler {
+	return input.NewPlain(d)
+}
+
+func newPickle(d input.Dispatcher) input.Handler {
+	return input.NewPickle(d)
+}
+
+// handler returns the handler to read a connection with. When connection
+// authentication is enabled, the handler is wrapped so that every connection
+// has to authenticate before its data is dispatched.
+func (c *Carbon) handler(newHandler func(input.Dispatcher) input.Handler) input.Handler {
+	if connAuth {
+		return newConnAuthHandler(c, newHandler)
+	}
+	return newHandler(c)
+}
+
+// startListener starts a listener for tcp and udp. handleData, if set, handles
+// the udp datagrams instead of the handler.
+func startListener(addr string, handler input.Handler, handleData func(l *input.Listener, data []byte, src net.Addr)) *input.Listener {
+	l := input.NewListener(addr, 2*time.Minute, handler)
+	l.HandleConn = handleConn
+	if handleData != nil {
+		l.HandleData = handleData
+	}
+	err := l.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return l
+}
+
 func (c *Carbon) Stop() {
-	if !Enabled {
+	if !anyEnabled() {
 		return
 	}
-	// note: this will only return when the handler is done too,
+	// note: this will only return when the handlers are done too,
 	// which means invocations of Dispatch() will be done too.
-	c.listener.Stop()
+	if c.listener != nil {
+		c.listener.Stop()
+	}
+	if c.pickleListener != nil {
+		c.pickleListener.Stop()
+	}
+	if c.tlsListener != nil {
+		c.tlsListener.Stop()
+	}
+	if c.udpListener != nil {
+		c.udpListener.Stop()
+	}
 	close(c.buf)
 	c.flushWg.Wait()
 }
 
-// IncNumInvalid does not apply for plain text, so is a no-op.
+// IncNumInvalid is only called by the pickle handler, for items that
+// could not be decoded. Plain text has no protocol-level failures.
 func (c *Carbon) IncNumInvalid() {
+	metricsRejected.Inc()
 }
 
 func (c *Carbon) Dispatch(buf []byte) {
+	c.dispatch(buf, nil, false)
+}
+
+// dispatch queues a line for flushing. user is set when the line was
+// received on a connection that already authenticated, in which case the
+// line is not expected to be prefixed with an api key. udp is set for lines
+// received by the UDP listener, so their rejections are counted separately.
+func (c *Carbon) dispatch(buf []byte, user *auth.User, udp bool) {
 	if len(buf) == 0 {
 		return
 	}
 	buf_copy := make([]byte, len(buf))
 	copy(buf_copy, buf)
 	metricsReceived.Inc()
+	l := line{
+		buf:  buf_copy,
+		user: user,
+		udp:  udp,
+	}
 	if nonBlockingBuffer {
 		select {
-		case c.buf <- buf_copy:
+		case c.buf <- l:
 		default:
 			metricsDroppedBufferFull.Inc()
 			log.Debugln("metric dropped due to full buffer")
 			// maybe we should just close the connection here
 		}
 	} else {
-		c.buf <- buf_copy
+		c.buf <- l
 	}
 }
 
+// auth validates the given credentials and makes sure the user is allowed to publish
+func (c *Carbon) auth(username, key string) (*auth.User, error) {
+	return auth.AuthPublisher(c.authPlugin, username, key, c.requirePublisher)
+}
+
 func (c *Carbon) flush() {
 	defer c.flushWg.Done()
 	buf := make([]*schema.MetricData, 0)
@@ -150,35 +266,63 @@ func (c *Carbon) flush() {
 				metricPool.Put(m)
 			}
 			buf = buf[0:0]
-		case b, ok := <-c.buf:
+		case l, ok := <-c.buf:
 			if !ok {
 				return
 			}
+			b := l.buf
 			_, _, _, err := m20.ValidatePacket(b, m20.StrictLegacy, m20.NoneM20)
 			if err != nil {
 				log.Debugf("packet rejected with error. %s - %s", err, b)
-				metricsRejected.Inc()
+				l.reject(metricsRejected)
 				continue
 			}
 
-			parts := bytes.SplitN(b, []byte("."), 2)
-			user, err := c.authPlugin.Auth("api_key", string(parts[0]))
+			user := l.user
+			if user == nil {
+				parts := bytes.SplitN(b, []byte("."), 2)
+				if len(parts) != 2 {
+					log.Debugf("packet rejected, no auth key prefix. %s", b)
+					l.reject(metricsDroppedAuthFail)
+					continue
+				}
+				user, err = c.auth("api_key", string(parts[0]))
+				if err != nil {
+					log.Debugf("invalid auth key. %s, reason: %v", parts[0], err)
+					l.reject(metricsDroppedAuthFail)
+					continue
+				}
+				b = parts[1]
+			}
+			md, err := parseMetric(b, c.schemas, user.ID)
 			if err != nil {
-				log.Debugf("invalid auth key. %s, reason: %v", parts[0], err)
-				metricsDroppedAuthFail.Inc()
+				log.Errorf("could not parse metric %q: %s", string(b), err)
+				l.reject(metricsRejected)
 				continue
 			}
-			if c.requirePublisher && !user.Role.IsPublisher() {
-				log.Debugf("invalid auth key. %s, reason: user does not have permissions to publish", parts[0])
-				metricsDroppedAuthFail.Inc()
+			if err := ingest.ValidateMetric(md); err != nil {
+				log.Debugf("metric of org %d rejected: %s. %s", user.ID, err, md.Name)
+				l.reject(metricsRejected)
+				ingest.CountDiscarded(user.ID, err.Error(), 1)
+				metricPool.Put(md)
 				continue
 			}
-			md, err := parseMetric(parts[1], c.schemas, user.ID)
-			if err != nil {
-				log.Errorf("could not parse metric %q: %s", string(parts[1]), err)
-				metricsRejected.Inc()
+			// carbon can't push back on a single org without stalling the
+			// connection for everyone behind it, so over-limit lines are dropped.
+			if !ingest.AllowDatapoints(user.ID, 1) {
+				log.Debugf("metric of org %d dropped due to rate limit. %s", user.ID, md.Name)
+				metricsDroppedRateLimit.Inc()
+				metricPool.Put(md)
+				continue
+			}
+			if err := ingest.CheckSeriesLimit(md); err != nil {
+				log.Debugf("metric of org %d dropped: %s. %s", user.ID, err, md.Name)
+				metricsDroppedSeriesLimit.Inc()
+				ingest.CountDiscarded(user.ID, err.Error(), 1)
+				metricPool.Put(md)
 				continue
 			}
+			ingest.ClampTimestamp(md)
 			metricTimestamp := getMetricsTimestampStat(user.ID)
 			metricTimestamp.ValueUint32(uint32(md.Time))
 			buf = append(buf, md)
@@ -0,0 +1,104 @@
+package carbon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/graphite-ng/carbon-relay-ng/input"
+	"github.com/raintank/tsdb-gw/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+var errAuthLine = errors.New("expected \"AUTH <instance> <key>\" as first line")
+
+// connAuthHandler authenticates a connection once, based on its first line,
+// and then hands the rest of the connection to the wrapped handler.
+// All data read from the connection is attributed to the authenticated user,
+// so metric names don't need to be prefixed with an api key.
+type connAuthHandler struct {
+	c          *Carbon
+	kind       string
+	newHandler func(input.Dispatcher) input.Handler
+}
+
+func newConnAuthHandler(c *Carbon, newHandler func(input.Dispatcher) input.Handler) *connAuthHandler {
+	return &connAuthHandler{
+		c:          c,
+		kind:       newHandler(nil).Kind(),
+		newHandler: newHandler,
+	}
+}
+
+func (h *connAuthHandler) Kind() string {
+	return h.kind
+}
+
+func (h *connAuthHandler) Handle(r io.Reader) error {
+	br := bufio.NewReader(r)
+	first, err := br.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(first) == 0) {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	username, key, err := parseAuthLine(first)
+	if err == nil {
+		var user *auth.User
+		user, err = h.c.auth(username, key)
+		if err == nil {
+			return h.newHandler(&connDispatcher{c: h.c, user: user}).Handle(br)
+		}
+	}
+
+	// the connection could not be authenticated. We keep reading so that
+	// everything the client sends is accounted for as dropped.
+	log.Debugf("%s handler: connection authentication failed: %s", h.kind, err)
+	h.newHandler(unauthDispatcher{}).Handle(br)
+	return err
+}
+
+// parseAuthLine parses a line in the format "AUTH <instance> <key>".
+// The instance may be omitted, in which case the key is treated as an api key.
+func parseAuthLine(buf []byte) (string, string, error) {
+	fields := bytes.Fields(buf)
+	if len(fields) < 2 || len(fields) > 3 || string(fields[0]) != "AUTH" {
+		return "", "", errAuthLine
+	}
+	if len(fields) == 2 {
+		return "api_key", string(fields[1]), nil
+	}
+	return string(fields[1]), string(fields[2]), nil
+}
+
+// connDispatcher dispatches lines on behalf of an authenticated connection
+type connDispatcher struct {
+	c    *Carbon
+	user *auth.User
+}
+
+func (d *connDispatcher) Dispatch(buf []byte) {
+	d.c.dispatch(buf, d.user, false)
+}
+
+func (d *connDispatcher) IncNumInvalid() {
+	d.c.IncNumInvalid()
+}
+
+// unauthDispatcher drops all lines of a connection that failed to authenticate
+type unauthDispatcher struct{}
+
+func (unauthDispatcher) Dispatch(buf []byte) {
+	if len(buf) == 0 {
+		return
+	}
+	metricsReceived.Inc()
+	metricsDroppedAuthFail.Inc()
+}
+
+func (unauthDispatcher) IncNumInvalid() {
+	metricsRejected.Inc()
+}
@@ -0,0 +1,54 @@
+package carbon
+
+import (
+	"testing"
+)
+
+func Test_parseAuthLine(t *testing.T) {
+	tests := []struct {
+		name         string
+		buf          []byte
+		wantUsername string
+		wantKey      string
+		wantErr      bool
+	}{
+		{
+			name:         "instance and key",
+			buf:          []byte("AUTH 10 secret\n"),
+			wantUsername: "10",
+			wantKey:      "secret",
+		},
+		{
+			name:         "key only",
+			buf:          []byte("AUTH secret\r\n"),
+			wantUsername: "api_key",
+			wantKey:      "secret",
+		},
+		{
+			name:    "metric line",
+			buf:     []byte("secret.test.metric 10 10\n"),
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			buf:     []byte("AUTH\n"),
+			wantErr: true,
+		},
+		{
+			name:    "too many fields",
+			buf:     []byte("AUTH 10 secret extra\n"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, key, err := parseAuthLine(tt.buf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAuthLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if username != tt.wantUsername || key != tt.wantKey {
+				t.Errorf("parseAuthLine() = %q, %q, want %q, %q", username, key, tt.wantUsername, tt.wantKey)
+			}
+		})
+	}
+}
@@ -12,10 +12,15 @@ import (
 //    (which itself is largely a consequence of the crng stats library creating a new connection to itself at every flush)
 // 2) it helps us do stats our way, with the metrics we care about and the library we use (crng uses a different library)
 func handleConn(l *input.Listener, c net.Conn) {
+	serveConn(l.Handler, c)
+}
+
+// serveConn reads the connection with the given handler until it is done
+func serveConn(h input.Handler, c net.Conn) {
 	carbonConnections.Inc()
-	log.Infof("%s handler: new tcp connection from %v", l.Handler.Kind(), c.RemoteAddr())
+	log.Infof("%s handler: new tcp connection from %v", h.Kind(), c.RemoteAddr())
 
-	err := l.Handler.Handle(c)
+	err := h.Handle(c)
 
 	carbonConnections.Dec()
 
@@ -26,8 +31,8 @@ func handleConn(l *input.Listener, c net.Conn) {
 		remoteInfo = " for " + rAddr.String()
 	}
 	if err != nil {
-		log.Warnf("%s handler%s returned: %s. closing conn", l.Handler.Kind(), remoteInfo, err)
+		log.Warnf("%s handler%s returned: %s. closing conn", h.Kind(), remoteInfo, err)
 		return
 	}
-	log.Infof("%s handler%s returned. closing conn", l.Handler.Kind(), remoteInfo)
+	log.Infof("%s handler%s returned. closing conn", h.Kind(), remoteInfo)
 }
@@ -0,0 +1,120 @@
+package carbon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	ogorek "github.com/kisielk/og-rek"
+)
+
+// pickleFrame returns a frame of the pickle protocol, as sent by carbon-relay
+// and carbon-c-relay: the length of the payload, and the payload itself,
+// a pickled list of (name, (timestamp, value)) tuples.
+func pickleFrame(t *testing.T, items []interface{}) []byte {
+	var payload bytes.Buffer
+	payload.Write([]byte{'\x80', '\x02'})
+	if err := ogorek.NewEncoder(&payload).Encode(items); err != nil {
+		t.Fatalf("failed to pickle %v: %s", items, err)
+	}
+	frame := make([]byte, 4, 4+payload.Len())
+	binary.BigEndian.PutUint32(frame, uint32(payload.Len()))
+	return append(frame, payload.Bytes()...)
+}
+
+// dispatched returns the lines the handler dispatched to c
+func dispatched(c *Carbon) []line {
+	var lines []line
+	for {
+		select {
+		case l := <-c.buf:
+			lines = append(lines, l)
+		default:
+			return lines
+		}
+	}
+}
+
+func TestPickle(t *testing.T) {
+	c := &Carbon{
+		buf: make(chan line, 10),
+	}
+
+	frame := pickleFrame(t, []interface{}{
+		ogorek.Tuple{"test.metric.a", ogorek.Tuple{int64(10), float64(1.5)}},
+		ogorek.Tuple{"test.metric.b", ogorek.Tuple{float64(20), int64(2)}},
+		ogorek.Tuple{"test.metric.c", ogorek.Tuple{int64(30)}},
+		"not a tuple",
+		ogorek.Tuple{"test.metric.d;tag=value", ogorek.Tuple{"40", "4"}},
+	})
+	rejected := metricsRejected.Peek()
+	if err := newPickle(c).Handle(bytes.NewReader(frame)); err != nil {
+		t.Fatalf("Handle() returned error: %s", err)
+	}
+	if got := metricsRejected.Peek() - rejected; got != 2 {
+		t.Errorf("expected the 2 malformed items to be rejected, got %d", got)
+	}
+	pickled := dispatched(c)
+
+	if err := newPlain(c).Handle(strings.NewReader("test.metric.a 1.5 10\ntest.metric.b 2 20\ntest.metric.d;tag=value 4 40\n")); err != nil {
+		t.Fatalf("Handle() returned error: %s", err)
+	}
+	plain := dispatched(c)
+
+	// the pickled items are dispatched as plain lines, so they're parsed and
+	// validated like the lines of the plain listener
+	if len(pickled) != len(plain) {
+		t.Fatalf("expected %d pickled lines, got %d: %v", len(plain), len(pickled), pickled)
+	}
+	for i := range plain {
+		if pickled[i].user != nil || pickled[i].udp {
+			t.Errorf("expected pickled line %q to be dispatched like a plain line", pickled[i].buf)
+		}
+		want, err := parseMetric(plain[i].buf, &schemas, 1)
+		if err != nil {
+			t.Fatalf("parseMetric(%q) returned error: %s", plain[i].buf, err)
+		}
+		got, err := parseMetric(pickled[i].buf, &schemas, 1)
+		if err != nil {
+			t.Fatalf("parseMetric(%q) returned error: %s", pickled[i].buf, err)
+		}
+		if got.Id != want.Id || got.Name != want.Name || got.Value != want.Value || got.Time != want.Time || strings.Join(got.Tags, ",") != strings.Join(want.Tags, ",") {
+			t.Errorf("pickled line %q parsed as %+v, expected %+v", pickled[i].buf, got, want)
+		}
+	}
+}
+
+func TestPickleMalformedFrame(t *testing.T) {
+	valid := pickleFrame(t, []interface{}{
+		ogorek.Tuple{"test.metric.a", ogorek.Tuple{int64(10), float64(1.5)}},
+	})
+
+	notAList := pickleFrame(t, []interface{}{})
+	notAList[6] = 'N' // a pickled None instead of the empty list
+
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"invalid prefix", notAList},
+		{"truncated payload", valid[:len(valid)-4]},
+		{"truncated length", valid[:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Carbon{
+				buf: make(chan line, 10),
+			}
+			// the frames before the malformed one are still dispatched
+			err := newPickle(c).Handle(bytes.NewReader(append(append([]byte{}, valid...), tt.frame...)))
+			if err == nil {
+				t.Errorf("expected Handle() to return an error")
+			}
+			lines := dispatched(c)
+			if len(lines) != 1 || string(lines[0].buf) != "test.metric.a 1.500000 10" {
+				t.Errorf("expected only the valid frame to be dispatched, got %v", lines)
+			}
+		})
+	}
+}
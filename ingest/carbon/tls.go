@@ -0,0 +1,134 @@
+package carbon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/graphite-ng/carbon-relay-ng/input"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	tlsEnabled      bool
+	tlsAddr         string
+	tlsCertFile     string
+	tlsKeyFile      string
+	tlsClientCAFile string
+)
+
+func init() {
+	flag.BoolVar(&tlsEnabled, "carbon-tls-enabled", false, "enable carbon input over TLS")
+	flag.StringVar(&tlsAddr, "carbon-tls-addr", "0.0.0.0:2443", "listen address for carbon input over TLS")
+	flag.StringVar(&tlsCertFile, "carbon-tls-cert-file", "", "SSL certificate file for carbon input over TLS")
+	flag.StringVar(&tlsKeyFile, "carbon-tls-key-file", "", "SSL key file for carbon input over TLS")
+	flag.StringVar(&tlsClientCAFile, "carbon-tls-client-ca-file", "", "if set, require clients to present a certificate signed by one of the CAs in this file")
+}
+
+func getTLSConfig() (*tls.Config, error) {
+	if tlsCertFile == "" || tlsKeyFile == "" {
+		return nil, errors.New("carbon-tls-cert-file and carbon-tls-key-file must be set when using TLS")
+	}
+	cert, err := tls.LoadX509KeyPair(tlsCertFile, tlsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if tlsClientCAFile != "" {
+		pem, err := ioutil.ReadFile(tlsClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", tlsClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// tlsListener accepts carbon connections over TLS.
+// The carbon-relay-ng listener only supports cleartext TCP and UDP,
+// so we manage the TLS socket ourselves and only reuse its handlers.
+type tlsListener struct {
+	addr     string
+	handler  input.Handler
+	listener net.Listener
+	wg       sync.WaitGroup
+	shutdown chan struct{}
+}
+
+func newTLSListener(addr string, config *tls.Config, handler input.Handler) (*tlsListener, error) {
+	l, err := tls.Listen("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	t := &tlsListener{
+		addr:     addr,
+		handler:  handler,
+		listener: l,
+		shutdown: make(chan struct{}),
+	}
+	log.Infof("listening on %v/tls", addr)
+	t.wg.Add(1)
+	go t.accept()
+	return t, nil
+}
+
+func (t *tlsListener) accept() {
+	defer t.wg.Done()
+	for {
+		c, err := t.listener.Accept()
+		if err != nil {
+			select {
+			case <-t.shutdown:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				log.Warnf("error accepting on %v/tls: %s", t.addr, err)
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			log.Errorf("error accepting on %v/tls, closing listener: %s", t.addr, err)
+			return
+		}
+
+		t.wg.Add(1)
+		go t.handle(c)
+	}
+}
+
+func (t *tlsListener) handle(c net.Conn) {
+	defer t.wg.Done()
+	connClose := make(chan struct{})
+	defer close(connClose)
+
+	go func() {
+		select {
+		case <-t.shutdown:
+			c.Close()
+		case <-connClose:
+		}
+	}()
+
+	serveConn(t.handler, input.NewTimeoutConn(c, 2*time.Minute))
+	c.Close()
+}
+
+func (t *tlsListener) Stop() {
+	log.Infof("shutting down %v/tls, closing socket", t.addr)
+	close(t.shutdown)
+	t.listener.Close()
+	t.wg.Wait()
+}
@@ -0,0 +1,143 @@
+package carbon
+
+import (
+	"bytes"
+	"flag"
+	"net"
+	"sync"
+
+	"github.com/grafana/metrictank/stats"
+	"github.com/graphite-ng/carbon-relay-ng/input"
+	"github.com/raintank/tsdb-gw/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	udpPacketsReceived = stats.NewCounterRate32("metrics.carbon.udp.packets_received")
+	udpMetricsReceived = stats.NewCounterRate32("metrics.carbon.udp.received")
+	udpMetricsRejected = stats.NewCounterRate32("metrics.carbon.udp.rejected")
+
+	udpEnabled    bool
+	udpAddr       string
+	udpReadBuffer int
+)
+
+func init() {
+	flag.BoolVar(&udpEnabled, "carbon-udp-enabled", false, "enable carbon input over UDP")
+	flag.StringVar(&udpAddr, "carbon-udp-addr", "0.0.0.0:2005", "listen address for carbon input over UDP. Must differ from carbon-addr, which accepts UDP as well")
+	flag.IntVar(&udpReadBuffer, "carbon-udp-read-buffer", 0, "size in bytes of the socket read buffer for carbon input over UDP. 0 means OS default")
+}
+
+// udpListener reads datagrams of newline separated carbon lines.
+type udpListener struct {
+	c        *Carbon
+	addr     string
+	conn     *net.UDPConn
+	wg       sync.WaitGroup
+	shutdown chan struct{}
+}
+
+func newUDPListener(c *Carbon, addr string, readBuffer int) (*udpListener, error) {
+	laddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	if readBuffer > 0 {
+		err = conn.SetReadBuffer(readBuffer)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	u := &udpListener{
+		c:        c,
+		addr:     addr,
+		conn:     conn,
+		shutdown: make(chan struct{}),
+	}
+	log.Infof("listening on %v/udp", addr)
+	u.wg.Add(1)
+	go u.consume()
+	return u, nil
+}
+
+func (u *udpListener) consume() {
+	defer u.wg.Done()
+	buffer := make([]byte, 65535)
+	for {
+		n, src, err := u.conn.ReadFrom(buffer)
+		if err != nil {
+			select {
+			case <-u.shutdown:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				log.Warnf("error reading packet on %v/udp: %s", u.addr, err)
+				continue
+			}
+			log.Errorf("error reading packet on %v/udp, closing listener: %s", u.addr, err)
+			return
+		}
+		log.Debugf("udp packet from %v (length: %d)", src, n)
+		u.c.handleDatagram(buffer[:n])
+	}
+}
+
+func (u *udpListener) Stop() {
+	log.Infof("shutting down %v/udp, closing socket", u.addr)
+	close(u.shutdown)
+	u.conn.Close()
+	u.wg.Wait()
+}
+
+// handleUDPData is used as HandleData of the plaintext listener,
+// so that datagrams it receives are treated like those of the udp listener.
+func (c *Carbon) handleUDPData(l *input.Listener, data []byte, src net.Addr) {
+	log.Debugf("udp packet from %v (length: %d)", src, len(data))
+	c.handleDatagram(data)
+}
+
+// handleDatagram dispatches each line of the datagram. Like the lines of the
+// other listeners, they are validated when flushed.
+// When connection authentication is enabled, every datagram must start with an
+// auth line, as there is no connection to carry it.
+func (c *Carbon) handleDatagram(data []byte) {
+	udpPacketsReceived.Inc()
+	lines := bytes.Split(data, []byte("\n"))
+
+	var user *auth.User
+	if connAuth {
+		username, key, err := parseAuthLine(lines[0])
+		if err == nil {
+			user, err = c.auth(username, key)
+		}
+		if err != nil {
+			log.Debugf("udp packet authentication failed: %s", err)
+			for _, l := range lines[1:] {
+				if len(bytes.TrimSpace(l)) == 0 {
+					continue
+				}
+				udpMetricsReceived.Inc()
+				metricsReceived.Inc()
+				metricsDroppedAuthFail.Inc()
+				udpMetricsRejected.Inc()
+			}
+			return
+		}
+		lines = lines[1:]
+	}
+
+	for _, l := range lines {
+		l = bytes.TrimSpace(l)
+		if len(l) == 0 {
+			continue
+		}
+		udpMetricsReceived.Inc()
+		c.dispatch(l, user, true)
+	}
+}
@@ -0,0 +1,55 @@
+package carbon
+
+import (
+	"testing"
+)
+
+func TestHandleDatagram(t *testing.T) {
+	c := &Carbon{
+		buf: make(chan line, 10),
+	}
+	c.handleDatagram([]byte("key.test.metric 10 10\nnot a valid line\n\nkey.test.other 1.5 20\r\n"))
+	close(c.buf)
+
+	var got []string
+	for l := range c.buf {
+		if l.user != nil {
+			t.Errorf("expected line %q to not be attributed to a user", l.buf)
+		}
+		if !l.udp {
+			t.Errorf("expected line %q to be marked as received over UDP", l.buf)
+		}
+		got = append(got, string(l.buf))
+	}
+	// invalid lines are only rejected when flushed
+	want := []string{"key.test.metric 10 10", "not a valid line", "key.test.other 1.5 20"}
+	if len(got) != len(want) {
+		t.Fatalf("handleDatagram() dispatched %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("handleDatagram() line %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLineReject(t *testing.T) {
+	rejected := metricsRejected.Peek()
+	udpRejected := udpMetricsRejected.Peek()
+
+	line{buf: []byte("not a valid line")}.reject(metricsRejected)
+	if got := metricsRejected.Peek() - rejected; got != 1 {
+		t.Errorf("rejecting a tcp line counted %d rejections, want 1", got)
+	}
+	if got := udpMetricsRejected.Peek() - udpRejected; got != 0 {
+		t.Errorf("rejecting a tcp line counted %d udp rejections, want 0", got)
+	}
+
+	line{buf: []byte("not a valid line"), udp: true}.reject(metricsRejected)
+	if got := metricsRejected.Peek() - rejected; got != 2 {
+		t.Errorf("rejecting a udp line counted %d rejections, want 2", got)
+	}
+	if got := udpMetricsRejected.Peek() - udpRejected; got != 1 {
+		t.Errorf("rejecting a udp line counted %d udp rejections, want 1", got)
+	}
+}
@@ -2,25 +2,57 @@ package datadog
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 
+	"github.com/gogo/protobuf/proto"
 	schema "github.com/grafana/metrictank/schema"
 	"github.com/raintank/tsdb-gw/api/models"
 	"github.com/raintank/tsdb-gw/ingest"
+	"github.com/raintank/tsdb-gw/ingest/datadog/payloads"
 	"github.com/raintank/tsdb-gw/publish"
 	log "github.com/sirupsen/logrus"
 )
 
+var countAsRate bool
+
+func init() {
+	flag.BoolVar(&countAsRate, "datadog-count-as-rate", false, "store datadog count metrics as per second rates, by dividing their values by their interval")
+}
+
 // DataDogSeriesPayload struct to unmarshal datadog agent json
 type DataDogSeriesPayload struct {
-	Series []struct {
-		Name   string       `json:"metric"`
-		Points [][2]float64 `json:"points"`
-		Tags   []string     `json:"tags"`
-		Host   string       `json:"host"`
-		Mtype  string       `json:"type"`
-		Device string       `json:"device,omitempty"`
-	} `json:"series"`
+	Series []Series `json:"series"`
+}
+
+// Series is a series of the v1 series api. The series of the other apis are
+// converted to it.
+type Series struct {
+	Name     string       `json:"metric"`
+	Points   [][2]float64 `json:"points"`
+	Tags     []string     `json:"tags"`
+	Host     string       `json:"host"`
+	Mtype    string       `json:"type"`
+	Interval int64        `json:"interval"`
+	Device   string       `json:"device,omitempty"`
+}
+
+// metricType returns the metrictank metric type of a datadog metric type, and
+// the factor to multiply the values with. The type of unknown types is empty,
+// which the validation rejects.
+func metricType(ddType string, interval int64) (string, float64) {
+	switch ddType {
+	case "", "gauge":
+		return "gauge", 1
+	case "rate":
+		return "rate", 1
+	case "count":
+		if countAsRate && interval > 0 {
+			return "rate", 1 / float64(interval)
+		}
+		return "count", 1
+	}
+	return "", 1
 }
 
 func DataDogSeries(ctx *models.Context) {
@@ -30,7 +62,7 @@ func DataDogSeries(ctx *models.Context) {
 	}
 	defer ctx.Req.Request.Body.Close()
 
-	data, err := decodeJSON(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding") == "deflate")
+	data, err := decodeBody(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding"))
 	if err != nil {
 		ctx.JSON(400, fmt.Sprintf("unable to decode request, reason: %v", err))
 		return
@@ -43,43 +75,123 @@ func DataDogSeries(ctx *models.Context) {
 		return
 	}
 
+	publishSeries(ctx, series.Series)
+}
+
+// DataDogSeriesV2 handles the protobuf payloads of the v2 series api
+func DataDogSeriesV2(ctx *models.Context) {
+	if ctx.Req.Request.Body == nil {
+		ctx.JSON(400, "no data included in request.")
+		return
+	}
+	defer ctx.Req.Request.Body.Close()
+
+	data, err := decodeBody(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding"))
+	if err != nil {
+		ctx.JSON(400, fmt.Sprintf("unable to decode request, reason: %v", err))
+		return
+	}
+
+	var payload payloads.MetricPayload
+	err = proto.Unmarshal(data, &payload)
+	if err != nil {
+		ctx.JSON(400, fmt.Sprintf("unable to unmarshal request, reason: %v", err))
+		return
+	}
+
+	publishSeries(ctx, seriesFromV2(payload))
+}
+
+// seriesFromV2 converts v2 series to v1 series. The host and device resources
+// become the host and device of the series, other resources become tags.
+func seriesFromV2(payload payloads.MetricPayload) []Series {
+	series := make([]Series, 0, len(payload.Series))
+	for _, s := range payload.Series {
+		ts := Series{
+			Name:     s.Metric,
+			Points:   make([][2]float64, 0, len(s.Points)),
+			Tags:     s.Tags,
+			Mtype:    s.Type.Name(),
+			Interval: s.Interval,
+		}
+		for _, r := range s.Resources {
+			switch r.Type {
+			case "host":
+				ts.Host = r.Name
+			case "device":
+				ts.Device = r.Name
+			default:
+				ts.Tags = append(ts.Tags, r.Type+":"+r.Name)
+			}
+		}
+		for _, p := range s.Points {
+			ts.Points = append(ts.Points, [2]float64{float64(p.Timestamp), p.Value})
+		}
+		series = append(series, ts)
+	}
+	return series
+}
+
+// publishSeries publishes the valid points of the series and responds
+func publishSeries(ctx *models.Context, series []Series) {
 	buf := make([]*schema.MetricData, 0)
-	defer func(buf []*schema.MetricData) {
+	defer func() {
 		for _, m := range buf {
 			ingest.MetricPool.Put(m)
 		}
-	}(buf)
+	}()
 
-	for _, ts := range series.Series {
+	for _, ts := range series {
 		tagSet := createTagSet(ts.Host, ts.Device, ts.Tags)
+		mtype, scale := metricType(ts.Mtype, ts.Interval)
+		interval := 0
+		if ts.Interval > 0 {
+			interval = int(ts.Interval)
+		}
 		for _, point := range ts.Points {
 			md := ingest.MetricPool.Get()
 			*md = schema.MetricData{
 				Name:     ts.Name,
-				Interval: 0,
-				Value:    point[1],
+				Interval: interval,
+				Value:    point[1] * scale,
 				Unit:     "unknown",
 				Time:     int64(point[0]),
-				Mtype:    ts.Mtype,
+				Mtype:    mtype,
 				Tags:     tagSet,
 				OrgId:    ctx.ID,
 			}
-			md.SetId()
 			buf = append(buf, md)
 		}
 	}
-	err = publish.Publish(buf)
+
+	resp := ingest.NewMetricsResponse()
+	batch := ingest.NewBatch()
+	toPublish := ingest.FilterMetrics(buf, &resp, batch)
+	if !ingest.RateLimitRequest(ctx, len(toPublish)) {
+		return
+	}
+
+	batch.Accept()
+	ingest.ClampTimestamps(toPublish)
+	err := publish.Publish(toPublish)
 
 	if err != nil {
 		log.Errorf("failed to publish datadog series metrics. %s", err)
-		ctx.JSON(500, err)
+		ctx.JSON(ingest.PublishErrorStatus(err), err.Error())
+		return
+	}
+	if resp.Invalid > 0 {
+		resp.Published = len(toPublish)
+		ctx.JSON(400, resp)
 		return
 	}
 	ctx.JSON(200, "ok")
 	return
 }
 
-// DataDogSeriesPayload struct to unmarshal datadog agent json
+// DataDogCheckPayload struct to unmarshal the service checks of the datadog agent.
+// The status of a check is stored as a gauge named after the check: 0 is ok, 1
+// warning, 2 critical and 3 unknown.
 type DataDogCheckPayload []struct {
 	Check     string   `json:"check"`
 	Host      string   `json:"host_name"`
@@ -96,9 +208,10 @@ func DataDogCheck(ctx *models.Context) {
 	}
 	defer ctx.Req.Request.Body.Close()
 
-	data, err := decodeJSON(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding") == "deflate")
+	data, err := decodeBody(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding"))
 	if err != nil {
 		ctx.JSON(400, fmt.Sprintf("unable to decode request, reason: %v", err))
+		return
 	}
 
 	var checks DataDogCheckPayload
@@ -106,14 +219,15 @@ func DataDogCheck(ctx *models.Context) {
 	err = json.Unmarshal(data, &checks)
 	if err != nil {
 		ctx.JSON(400, fmt.Sprintf("unable to unmarshal request, reason: %v", err))
+		return
 	}
 
-	buf := make([]*schema.MetricData, 0)
-	defer func(buf []*schema.MetricData) {
+	buf := make([]*schema.MetricData, 0, len(checks))
+	defer func() {
 		for _, m := range buf {
 			ingest.MetricPool.Put(m)
 		}
-	}(buf)
+	}()
 
 	for _, check := range checks {
 		tagSet := createTagSet(check.Host, "", check.Tags)
@@ -128,18 +242,31 @@ func DataDogCheck(ctx *models.Context) {
 			Tags:     tagSet,
 			OrgId:    ctx.ID,
 		}
-		md.SetId()
 		buf = append(buf, md)
 	}
 
-	err = publish.Publish(buf)
+	resp := ingest.NewMetricsResponse()
+	batch := ingest.NewBatch()
+	toPublish := ingest.FilterMetrics(buf, &resp, batch)
+	if !ingest.RateLimitRequest(ctx, len(toPublish)) {
+		return
+	}
+
+	batch.Accept()
+	ingest.ClampTimestamps(toPublish)
+	err = publish.Publish(toPublish)
 
 	if err != nil {
 		log.Errorf("failed to publish datadog metrics. %s", err)
-		ctx.JSON(500, err)
+		ctx.JSON(ingest.PublishErrorStatus(err), err.Error())
 		return
 	}
 
+	if resp.Invalid > 0 {
+		resp.Published = len(toPublish)
+		ctx.JSON(400, resp)
+		return
+	}
 	ctx.JSON(200, "ok")
 	return
 }
@@ -1,8 +1,16 @@
 package datadog
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/klauspost/compress/zstd"
+	"github.com/raintank/tsdb-gw/ingest/datadog/payloads"
 )
 
 func Test_createTagSet(t *testing.T) {
@@ -43,3 +51,150 @@ func Test_createTagSet(t *testing.T) {
 		})
 	}
 }
+
+func Test_metricType(t *testing.T) {
+	tests := []struct {
+		name        string
+		ddType      string
+		interval    int64
+		countAsRate bool
+		wantMtype   string
+		wantScale   float64
+	}{
+		{"gauge", "gauge", 10, false, "gauge", 1},
+		{"no type", "", 0, false, "gauge", 1},
+		{"rate", "rate", 10, false, "rate", 1},
+		{"count", "count", 10, false, "count", 1},
+		{"count as rate", "count", 10, true, "rate", 0.1},
+		{"count as rate without interval", "count", 0, true, "count", 1},
+		{"unknown", "histogram", 10, false, "", 1},
+	}
+	defer func() { countAsRate = false }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			countAsRate = tt.countAsRate
+			mtype, scale := metricType(tt.ddType, tt.interval)
+			if mtype != tt.wantMtype || scale != tt.wantScale {
+				t.Errorf("metricType() = %v, %v, want %v, %v", mtype, scale, tt.wantMtype, tt.wantScale)
+			}
+		})
+	}
+}
+
+func Test_seriesFromV2(t *testing.T) {
+	data, err := proto.Marshal(&payloads.MetricPayload{
+		Series: []*payloads.MetricSeries{
+			{
+				Resources: []*payloads.Resource{{Type: "host", Name: "web01"}, {Type: "device", Name: "sda0"}, {Type: "cluster", Name: "a"}},
+				Metric:    "system.disk.read",
+				Tags:      []string{"env:prod"},
+				Points:    []*payloads.MetricPoint{{Value: 1.5, Timestamp: 1000}, {Value: 2, Timestamp: 1010}},
+				Type:      payloads.MetricTypeRate,
+				Interval:  10,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload payloads.MetricPayload
+	if err := proto.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Series{{
+		Name:     "system.disk.read",
+		Points:   [][2]float64{{1000, 1.5}, {1010, 2}},
+		Tags:     []string{"env:prod", "cluster:a"},
+		Host:     "web01",
+		Mtype:    "rate",
+		Interval: 10,
+		Device:   "sda0",
+	}}
+	if got := seriesFromV2(payload); !reflect.DeepEqual(got, want) {
+		t.Errorf("seriesFromV2() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_seriesFromDistributions(t *testing.T) {
+	var payload DataDogDistributionPayload
+	err := json.Unmarshal([]byte(`{"series": [{"metric": "latency", "host": "web01", "tags": ["env:prod"], "points": [[1000, [4, 1, 3, 2]], [1010, []], [1020, [5]]]}]}`), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := seriesFromDistributions(payload, []float64{50, 99.9}, []string{"count", "sum", "max"})
+	want := []struct {
+		name   string
+		mtype  string
+		points [][2]float64
+	}{
+		{"latency.count", "count", [][2]float64{{1000, 4}, {1020, 1}}},
+		{"latency.sum", "count", [][2]float64{{1000, 10}, {1020, 5}}},
+		{"latency.max", "gauge", [][2]float64{{1000, 4}, {1020, 5}}},
+		{"latency.p50", "gauge", [][2]float64{{1000, 2}, {1020, 5}}},
+		{"latency.p99_9", "gauge", [][2]float64{{1000, 4}, {1020, 5}}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d series, got %d", len(want), len(got))
+	}
+	for i, w := range want {
+		if got[i].Name != w.name || got[i].Mtype != w.mtype || !reflect.DeepEqual(got[i].Points, w.points) || got[i].Host != "web01" || got[i].Tags[0] != "env:prod" {
+			t.Errorf("series %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func Test_parseDistributionSettings(t *testing.T) {
+	tests := []struct {
+		percentiles string
+		aggregates  string
+		wantErr     bool
+	}{
+		{"50, 99.9", "count,sum,min,max,avg", false},
+		{"", "", false},
+		{"0", "count", true},
+		{"101", "count", true},
+		{"p99", "count", true},
+		{"50", "median", true},
+	}
+	for _, tt := range tests {
+		if _, _, err := parseDistributionSettings(tt.percentiles, tt.aggregates); (err != nil) != tt.wantErr {
+			t.Errorf("parseDistributionSettings(%q, %q) error = %v, wantErr %v", tt.percentiles, tt.aggregates, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_decodeBody(t *testing.T) {
+	payload := []byte(`{"series": []}`)
+	var deflated, gzipped bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	zw.Write(payload)
+	zw.Close()
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write(payload)
+	gw.Close()
+	enc, _ := zstd.NewWriter(nil)
+	zstded := enc.EncodeAll(payload, nil)
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		wantErr  bool
+	}{
+		{"", payload, false},
+		{"deflate", deflated.Bytes(), false},
+		{"gzip", gzipped.Bytes(), false},
+		{"zstd", zstded, false},
+		{"br", payload, true},
+	}
+	for _, tt := range tests {
+		got, err := decodeBody(bytes.NewReader(tt.body), tt.encoding)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("decodeBody(%q) error = %v, wantErr %v", tt.encoding, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !bytes.Equal(got, payload) {
+			t.Errorf("decodeBody(%q) = %s, want %s", tt.encoding, got, payload)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package datadog
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/raintank/tsdb-gw/api/models"
+	"github.com/raintank/tsdb-gw/ingest"
+)
+
+var (
+	distributionPercentilesStr string
+	distributionAggregatesStr  string
+
+	distributionPercentiles []float64
+	distributionAggregates  []string
+)
+
+func init() {
+	flag.StringVar(&distributionPercentilesStr, "datadog-distribution-percentiles", "50,90,95,99", "comma separated percentiles of datadog distributions to store, each as a <metric>.p<percentile> series")
+	flag.StringVar(&distributionAggregatesStr, "datadog-distribution-aggregates", "count,sum", "comma separated aggregates of datadog distributions to store, each as a <metric>.<aggregate> series (count|sum|min|max|avg)")
+}
+
+// DataDogDistributionPayload struct to unmarshal the payloads of the
+// distribution points api. The points are [<timestamp>, [<value>, ...]].
+type DataDogDistributionPayload struct {
+	Series []struct {
+		Name   string              `json:"metric"`
+		Points []DistributionPoint `json:"points"`
+		Tags   []string            `json:"tags"`
+		Host   string              `json:"host"`
+		Device string              `json:"device,omitempty"`
+	} `json:"series"`
+}
+
+type DistributionPoint struct {
+	Timestamp float64
+	Values    []float64
+}
+
+func (p *DistributionPoint) UnmarshalJSON(data []byte) error {
+	var point [2]json.RawMessage
+	if err := json.Unmarshal(data, &point); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(point[0], &p.Timestamp); err != nil {
+		return err
+	}
+	return json.Unmarshal(point[1], &p.Values)
+}
+
+// Init parses the settings of the datadog ingest
+func Init() error {
+	var err error
+	distributionPercentiles, distributionAggregates, err = parseDistributionSettings(distributionPercentilesStr, distributionAggregatesStr)
+	return err
+}
+
+func parseDistributionSettings(percentilesStr, aggregatesStr string) ([]float64, []string, error) {
+	percentiles, err := ingest.ParsePercentiles(percentilesStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid datadog distribution percentiles. %s", err)
+	}
+	var aggregates []string
+	for _, a := range strings.Split(aggregatesStr, ",") {
+		a = strings.TrimSpace(a)
+		switch a {
+		case "":
+			continue
+		case "count", "sum", "min", "max", "avg":
+			aggregates = append(aggregates, a)
+		default:
+			return nil, nil, fmt.Errorf("invalid datadog distribution aggregate %q", a)
+		}
+	}
+	return percentiles, aggregates, nil
+}
+
+// DataDogDistributionPoints handles the distribution points api. Each
+// distribution is stored as the configured percentiles and aggregates of its values.
+func DataDogDistributionPoints(ctx *models.Context) {
+	if ctx.Req.Request.Body == nil {
+		ctx.JSON(400, "no data included in request.")
+		return
+	}
+	defer ctx.Req.Request.Body.Close()
+
+	data, err := decodeBody(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding"))
+	if err != nil {
+		ctx.JSON(400, fmt.Sprintf("unable to decode request, reason: %v", err))
+		return
+	}
+
+	var payload DataDogDistributionPayload
+	err = json.Unmarshal(data, &payload)
+	if err != nil {
+		ctx.JSON(400, fmt.Sprintf("unable to unmarshal request, reason: %v", err))
+		return
+	}
+
+	publishSeries(ctx, seriesFromDistributions(payload, distributionPercentiles, distributionAggregates))
+}
+
+// seriesFromDistributions converts each distribution to a series per percentile
+// and aggregate. Counts and sums are counts, the others are gauges. Points
+// without values are skipped.
+func seriesFromDistributions(payload DataDogDistributionPayload, percentiles []float64, aggregates []string) []Series {
+	var series []Series
+	for _, d := range payload.Series {
+		first := len(series)
+		for _, aggregate := range aggregates {
+			mtype := "gauge"
+			if aggregate == "count" || aggregate == "sum" {
+				mtype = "count"
+			}
+			series = append(series, Series{Name: d.Name + "." + aggregate, Mtype: mtype})
+		}
+		for _, p := range percentiles {
+			series = append(series, Series{Name: ingest.PercentileName(d.Name, p), Mtype: "gauge"})
+		}
+		for i := first; i < len(series); i++ {
+			series[i].Tags = d.Tags
+			series[i].Host = d.Host
+			series[i].Device = d.Device
+		}
+
+		for _, point := range d.Points {
+			if len(point.Values) == 0 {
+				continue
+			}
+			values := make([]float64, len(point.Values))
+			copy(values, point.Values)
+			sort.Float64s(values)
+			i := first
+			for _, aggregate := range aggregates {
+				series[i].Points = append(series[i].Points, [2]float64{point.Timestamp, aggregateValues(values, aggregate)})
+				i++
+			}
+			for _, p := range percentiles {
+				series[i].Points = append(series[i].Points, [2]float64{point.Timestamp, ingest.Percentile(values, p)})
+				i++
+			}
+		}
+	}
+	return series
+}
+
+// aggregateValues returns the aggregate of the sorted values
+func aggregateValues(values []float64, aggregate string) float64 {
+	switch aggregate {
+	case "count":
+		return float64(len(values))
+	case "min":
+		return values[0]
+	case "max":
+		return values[len(values)-1]
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	if aggregate == "avg" {
+		return sum / float64(len(values))
+	}
+	return sum
+}
@@ -10,6 +10,8 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// DataDogIntake forwards the host metadata sent by datadog agents to the
+// persister-gw, which stores it and publishes metrics derived from it.
 func DataDogIntake(ctx *models.Context) {
 	if ctx.Req.Request.Body == nil {
 		ctx.JSON(400, "no data included in request.")
@@ -17,7 +19,7 @@ func DataDogIntake(ctx *models.Context) {
 	}
 	defer ctx.Req.Request.Body.Close()
 
-	data, err := decodeJSON(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding") == "deflate")
+	data, err := decodeBody(ctx.Req.Request.Body, ctx.Req.Request.Header.Get("Content-Encoding"))
 	if err != nil {
 		ctx.JSON(400, fmt.Sprintf("unable to decode request, reason: %v", err))
 		return
@@ -35,13 +37,13 @@ func DataDogIntake(ctx *models.Context) {
 		payload, err := json.Marshal(payloads.PersistPayload{OrgID: ctx.ID, Hostname: info.InternalHostname, Raw: data})
 		if err != nil {
 			log.Errorf("failed to persist datadog info. %s", err)
-			ctx.JSON(500, err)
+			ctx.JSON(500, err.Error())
 			return
 		}
 		err = persist.Persist(payload)
 		if err != nil {
 			log.Errorf("failed to persist datadog info. %s", err)
-			ctx.JSON(500, err)
+			ctx.JSON(502, err.Error())
 			return
 		}
 	}
@@ -0,0 +1,89 @@
+package payloads
+
+import (
+	"github.com/gogo/protobuf/proto"
+)
+
+// MetricPayload is the protobuf payload of the v2 series api, as defined by
+// the agent-payload repository of DataDog:
+//
+//	message MetricPayload {
+//	  repeated MetricSeries series = 1;
+//	}
+//	message MetricSeries {
+//	  repeated Resource resources = 1;
+//	  string metric = 2;
+//	  repeated string tags = 3;
+//	  repeated MetricPoint points = 4;
+//	  MetricType type = 5;
+//	  string unit = 6;
+//	  string source_type_name = 7;
+//	  int64 interval = 8;
+//	}
+//
+// Only the fields used by tsdb-gw are declared, the others are skipped when
+// decoding.
+type MetricPayload struct {
+	Series []*MetricSeries `protobuf:"bytes,1,rep,name=series"`
+}
+
+func (m *MetricPayload) Reset()         { *m = MetricPayload{} }
+func (m *MetricPayload) String() string { return proto.CompactTextString(m) }
+func (*MetricPayload) ProtoMessage()    {}
+
+// MetricType is the type of a v2 series
+type MetricType int32
+
+const (
+	MetricTypeUnspecified MetricType = 0
+	MetricTypeCount       MetricType = 1
+	MetricTypeRate        MetricType = 2
+	MetricTypeGauge       MetricType = 3
+)
+
+// Name returns the name of the type in v1 series payloads
+func (t MetricType) Name() string {
+	switch t {
+	case MetricTypeUnspecified:
+		return ""
+	case MetricTypeCount:
+		return "count"
+	case MetricTypeRate:
+		return "rate"
+	case MetricTypeGauge:
+		return "gauge"
+	}
+	return "unknown"
+}
+
+type MetricSeries struct {
+	Resources []*Resource    `protobuf:"bytes,1,rep,name=resources"`
+	Metric    string         `protobuf:"bytes,2,opt,name=metric,proto3"`
+	Tags      []string       `protobuf:"bytes,3,rep,name=tags"`
+	Points    []*MetricPoint `protobuf:"bytes,4,rep,name=points"`
+	Type      MetricType     `protobuf:"varint,5,opt,name=type,proto3"`
+	Interval  int64          `protobuf:"varint,8,opt,name=interval,proto3"`
+}
+
+func (m *MetricSeries) Reset()         { *m = MetricSeries{} }
+func (m *MetricSeries) String() string { return proto.CompactTextString(m) }
+func (*MetricSeries) ProtoMessage()    {}
+
+// Resource is an entity the series is about, e.g. {Type: "host", Name: "web01"}
+type Resource struct {
+	Type string `protobuf:"bytes,1,opt,name=type,proto3"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3"`
+}
+
+func (m *Resource) Reset()         { *m = Resource{} }
+func (m *Resource) String() string { return proto.CompactTextString(m) }
+func (*Resource) ProtoMessage()    {}
+
+type MetricPoint struct {
+	Value     float64 `protobuf:"fixed64,1,opt,name=value,proto3"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=timestamp,proto3"`
+}
+
+func (m *MetricPoint) Reset()         { *m = MetricPoint{} }
+func (m *MetricPoint) String() string { return proto.CompactTextString(m) }
+func (*MetricPoint) ProtoMessage()    {}
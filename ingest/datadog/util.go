@@ -1,11 +1,15 @@
 package datadog
 
 import (
+	"compress/gzip"
 	"compress/zlib"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"sort"
 	"strings"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 func createTagSet(host string, device string, ctags []string) []string {
@@ -33,14 +37,33 @@ func createTagSet(host string, device string, ctags []string) []string {
 	return tags
 }
 
-func decodeJSON(body io.ReadCloser, encoded bool) ([]byte, error) {
-	var err error
-	if encoded {
-		body, err = zlib.NewReader(body)
+// decodeBody returns the body of a request, decompressed according to its content encoding
+func decodeBody(body io.Reader, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+	case "deflate":
+		r, err := zlib.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		body = r
+	case "gzip":
+		r, err := gzip.NewReader(body)
 		if err != nil {
 			return nil, err
 		}
+		defer r.Close()
+		body = r
+	case "zstd":
+		r, err := zstd.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		body = r
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
 	}
 	return ioutil.ReadAll(body)
-
 }
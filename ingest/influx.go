@@ -0,0 +1,382 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	schema "github.com/grafana/metrictank/schema"
+	"github.com/raintank/tsdb-gw/api/models"
+	"github.com/raintank/tsdb-gw/publish"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	errInfluxMissingMeasurement = errors.New("missing measurement")
+	errInfluxMissingFields      = errors.New("missing fields")
+	errInfluxMissingTagValue    = errors.New("missing tag value")
+	errInfluxMissingFieldValue  = errors.New("missing field value")
+	errInfluxInvalidFieldValue  = errors.New("invalid field value")
+	errInfluxUnterminatedString = errors.New("unterminated string")
+	errInfluxInvalidTimestamp   = errors.New("invalid timestamp")
+
+	// timestamps of each precision are converted to seconds by dividing them
+	// by perSecond, or multiplying them by seconds
+	influxPrecisions = map[string]struct {
+		perSecond int64
+		seconds   int64
+	}{
+		"":   {perSecond: 1e9},
+		"ns": {perSecond: 1e9},
+		"n":  {perSecond: 1e9},
+		"us": {perSecond: 1e6},
+		"u":  {perSecond: 1e6},
+		"ms": {perSecond: 1e3},
+		"s":  {seconds: 1},
+		"m":  {seconds: 60},
+		"h":  {seconds: 3600},
+	}
+)
+
+// maxInfluxLineErrors is the max number of line errors reported in a response
+const maxInfluxLineErrors = 10
+
+type influxField struct {
+	key   string
+	value float64
+}
+
+// influxLine is a parsed line of line protocol
+type influxLine struct {
+	measurement string
+	tags        []string
+	fields      []influxField
+	timestamp   int64 // in the precision of the request
+	hasTime     bool
+}
+
+// InfluxWriteV1 handles the /write api of InfluxDB 1.x
+func InfluxWriteV1(ctx *models.Context) {
+	influxWrite(ctx, false)
+}
+
+// InfluxWriteV2 handles the /api/v2/write api of InfluxDB 2.x
+func InfluxWriteV2(ctx *models.Context) {
+	influxWrite(ctx, true)
+}
+
+func influxWrite(ctx *models.Context, v2 bool) {
+	if ctx.Req.Request.Body == nil {
+		influxError(ctx, v2, 400, "no data included in request.")
+		return
+	}
+	defer ctx.Req.Request.Body.Close()
+
+	precision := ctx.Query("precision")
+	if _, ok := influxPrecisions[precision]; !ok {
+		influxError(ctx, v2, 400, fmt.Sprintf("invalid precision %q", precision))
+		return
+	}
+
+	var reader io.Reader = ctx.Req.Request.Body
+	if ctx.Req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(ctx.Req.Request.Body)
+		if err != nil {
+			influxError(ctx, v2, 400, err.Error())
+			return
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		influxError(ctx, v2, 400, err.Error())
+		log.Errorf("Read Error, %v", err)
+		return
+	}
+
+	batch := NewBatch()
+	buf, lineErrors, lines := prepareInfluxIngest(ctx.ID, body, precision, time.Now(), batch)
+	if !RateLimitRequest(ctx, len(buf)) {
+		for _, m := range buf {
+			MetricPool.Put(m)
+		}
+		return
+	}
+
+	batch.Accept()
+	ClampTimestamps(buf)
+	err = publish.Publish(buf)
+	for _, m := range buf {
+		MetricPool.Put(m)
+	}
+	if err != nil {
+		log.Errorf("failed to publish influx write metrics. %s", err)
+		influxError(ctx, v2, PublishErrorStatus(err), err.Error())
+		return
+	}
+
+	if len(lineErrors) > 0 {
+		influxError(ctx, v2, 400, influxLineErrorsMessage(lineErrors, lines))
+		return
+	}
+	ctx.Resp.WriteHeader(204)
+}
+
+// influxError responds with an error in the format of the InfluxDB api version
+func influxError(ctx *models.Context, v2 bool, status int, message string) {
+	if v2 {
+		code := "invalid"
+		switch {
+		case status == 429:
+			code = "too many requests"
+		case status >= 500:
+			code = "internal error"
+		}
+		ctx.JSON(status, map[string]string{"code": code, "message": message})
+		return
+	}
+	ctx.JSON(status, map[string]string{"error": message})
+}
+
+// influxLineErrorsMessage describes the errors of the lines. Lines with an
+// error are not written, the other lines are.
+func influxLineErrorsMessage(lineErrors []string, lines int) string {
+	prefix := "partial write: "
+	if len(lineErrors) == lines {
+		prefix = ""
+	}
+	if len(lineErrors) > maxInfluxLineErrors {
+		more := fmt.Sprintf("and %d more", len(lineErrors)-maxInfluxLineErrors)
+		lineErrors = append(lineErrors[:maxInfluxLineErrors:maxInfluxLineErrors], more)
+	}
+	return prefix + "errors encountered on line(s):\n" + strings.Join(lineErrors, "\n")
+}
+
+// prepareInfluxIngest converts the lines of line protocol in body to metrics,
+// one per numeric field, named <measurement>.<field>, and adds them to batch.
+// Lines with an error are skipped as a whole, and described in the returned
+// errors. It also returns the number of lines.
+func prepareInfluxIngest(orgId int, body []byte, precision string, now time.Time, batch *Batch) ([]*schema.MetricData, []string, int) {
+	var buf []*schema.MetricData
+	var lineErrors []string
+	discards := make(discardsByOrg)
+	metricTimestamp := getMetricsTimestampStat(orgId)
+	lines := 0
+	rejected := 0
+
+	for lineNum, raw := range bytes.Split(body, []byte("\n")) {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || raw[0] == '#' {
+			continue
+		}
+		lines++
+		line, err := parseInfluxLine(raw)
+		var ts int64
+		if err == nil {
+			ts, err = influxTimestamp(line, precision, now)
+		}
+		var mds []*schema.MetricData
+		if err == nil {
+			for _, field := range line.fields {
+				md := MetricPool.Get()
+				*md = schema.MetricData{
+					Name:     line.measurement + "." + field.key,
+					Interval: 0,
+					Value:    field.value,
+					Unit:     "unknown",
+					Time:     ts,
+					Mtype:    "gauge",
+					Tags:     line.tags,
+					OrgId:    orgId,
+				}
+				mds = append(mds, md)
+				if err == nil {
+					err = validateAndSetId(md)
+				}
+			}
+		}
+		if err == nil {
+			err = batch.Add(mds...)
+		}
+		if err != nil {
+			for _, md := range mds {
+				MetricPool.Put(md)
+			}
+			lineErrors = append(lineErrors, fmt.Sprintf("line %d: %s", lineNum+1, err))
+			discards.Add(orgId, err.Error())
+			rejected += len(line.fields)
+			continue
+		}
+		for _, md := range mds {
+			metricTimestamp.ValueUint32(uint32(md.Time))
+		}
+		buf = append(buf, mds...)
+	}
+
+	metricsRejected.Add(rejected)
+	metricsValid.Add(len(buf))
+	discards.Track()
+	return buf, lineErrors, lines
+}
+
+// influxTimestamp returns the timestamp of the line in seconds
+func influxTimestamp(line influxLine, precision string, now time.Time) (int64, error) {
+	if !line.hasTime {
+		return now.Unix(), nil
+	}
+	p := influxPrecisions[precision]
+	if p.perSecond > 0 {
+		return line.timestamp / p.perSecond, nil
+	}
+	if line.timestamp > math.MaxInt64/p.seconds || line.timestamp < math.MinInt64/p.seconds {
+		return 0, errInfluxInvalidTimestamp
+	}
+	return line.timestamp * p.seconds, nil
+}
+
+// parseInfluxLine parses a line of line protocol:
+//
+//	<measurement>[,<tag key>=<tag value>...] <field key>=<field value>[,<field key>=<field value>...] [<timestamp>]
+//
+// Commas and spaces may be escaped with a backslash in the measurement, and so
+// may equal signs in tag keys, tag values and field keys. String fields are
+// skipped, as metrictank only stores numbers. Booleans are stored as 1 and 0.
+func parseInfluxLine(buf []byte) (influxLine, error) {
+	var line influxLine
+	token, i := scanInfluxToken(buf, 0, ", ")
+	if len(token) == 0 {
+		return line, errInfluxMissingMeasurement
+	}
+	line.measurement = token
+
+	for i < len(buf) && buf[i] == ',' {
+		var key, value string
+		key, i = scanInfluxToken(buf, i+1, "=, ")
+		if i >= len(buf) || buf[i] != '=' {
+			return line, errInfluxMissingTagValue
+		}
+		value, i = scanInfluxToken(buf, i+1, ", ")
+		if key == "" || value == "" {
+			return line, errInfluxMissingTagValue
+		}
+		line.tags = append(line.tags, key+"="+value)
+	}
+
+	for i < len(buf) && buf[i] == ' ' {
+		i++
+	}
+	if i >= len(buf) {
+		return line, errInfluxMissingFields
+	}
+	numFields := 0
+	for {
+		var key string
+		key, i = scanInfluxToken(buf, i, "=, ")
+		if key == "" || i >= len(buf) || buf[i] != '=' {
+			return line, errInfluxMissingFieldValue
+		}
+		i++
+		if i >= len(buf) || buf[i] == ' ' || buf[i] == ',' {
+			return line, errInfluxMissingFieldValue
+		}
+		numFields++
+		if buf[i] == '"' {
+			// skip the string
+			i++
+			for ; i < len(buf) && buf[i] != '"'; i++ {
+				if buf[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(buf) {
+				return line, errInfluxUnterminatedString
+			}
+			i++
+		} else {
+			start := i
+			for i < len(buf) && buf[i] != ',' && buf[i] != ' ' {
+				i++
+			}
+			value, err := parseInfluxFieldValue(string(buf[start:i]))
+			if err != nil {
+				return line, err
+			}
+			line.fields = append(line.fields, influxField{key, value})
+		}
+		if i >= len(buf) || buf[i] != ',' {
+			break
+		}
+		i++
+	}
+	if numFields == 0 {
+		return line, errInfluxMissingFields
+	}
+
+	for i < len(buf) && buf[i] == ' ' {
+		i++
+	}
+	if i < len(buf) {
+		ts, err := strconv.ParseInt(string(bytes.TrimSpace(buf[i:])), 10, 64)
+		if err != nil {
+			return line, errInfluxInvalidTimestamp
+		}
+		line.timestamp = ts
+		line.hasTime = true
+	}
+	return line, nil
+}
+
+// scanInfluxToken returns the unescaped token starting at i, up to the first
+// unescaped character of stops, and the position of that character
+func scanInfluxToken(buf []byte, i int, stops string) (string, int) {
+	var token []byte
+	for ; i < len(buf); i++ {
+		c := buf[i]
+		if c == '\\' && i+1 < len(buf) && strings.IndexByte(",= \\", buf[i+1]) >= 0 {
+			i++
+			token = append(token, buf[i])
+			continue
+		}
+		if strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		token = append(token, c)
+	}
+	return string(token), i
+}
+
+func parseInfluxFieldValue(s string) (float64, error) {
+	switch s {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, nil
+	}
+	switch s[len(s)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, errInfluxInvalidFieldValue
+		}
+		return float64(v), nil
+	case 'u':
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, errInfluxInvalidFieldValue
+		}
+		return float64(v), nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errInfluxInvalidFieldValue
+	}
+	return v, nil
+}
@@ -0,0 +1,162 @@
+package ingest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected influxLine
+		err      error
+	}{
+		{
+			line: `cpu,host=a,dc=b usage=1.5,count=3i,up=true,down=F 1500000000000000000`,
+			expected: influxLine{
+				measurement: "cpu",
+				tags:        []string{"host=a", "dc=b"},
+				fields:      []influxField{{"usage", 1.5}, {"count", 3}, {"up", 1}, {"down", 0}},
+				timestamp:   1500000000000000000,
+				hasTime:     true,
+			},
+		},
+		{
+			line: `my\ cpu,host\=name=a\,b\ c total=5u,msg="hello, \"world\" x=1"`,
+			expected: influxLine{
+				measurement: "my cpu",
+				tags:        []string{"host=name=a,b c"},
+				fields:      []influxField{{"total", 5}},
+			},
+		},
+		{
+			line:     `cpu msg="only strings"`,
+			expected: influxLine{measurement: "cpu"},
+		},
+		{line: `,host=a value=1`, err: errInfluxMissingMeasurement},
+		{line: `cpu,host value=1`, err: errInfluxMissingTagValue},
+		{line: `cpu,host= value=1`, err: errInfluxMissingTagValue},
+		{line: `cpu`, err: errInfluxMissingFields},
+		{line: `cpu value=`, err: errInfluxMissingFieldValue},
+		{line: `cpu value`, err: errInfluxMissingFieldValue},
+		{line: `cpu value=abc`, err: errInfluxInvalidFieldValue},
+		{line: `cpu value=NaN`, err: errInfluxInvalidFieldValue},
+		{line: `cpu value=1.5i`, err: errInfluxInvalidFieldValue},
+		{line: `cpu msg="unterminated`, err: errInfluxUnterminatedString},
+		{line: `cpu value=1 abc`, err: errInfluxInvalidTimestamp},
+	}
+	for _, test := range tests {
+		line, err := parseInfluxLine([]byte(test.line))
+		if err != test.err {
+			t.Errorf("%s: expected error %v, got %v", test.line, test.err, err)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(line, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.line, test.expected, line)
+		}
+	}
+}
+
+func TestInfluxTimestamp(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tests := []struct {
+		precision string
+		timestamp int64
+		hasTime   bool
+		expected  int64
+		err       error
+	}{
+		{"", 0, false, 1500000000, nil},
+		{"", 1400000000123456789, true, 1400000000, nil},
+		{"ns", 1400000000123456789, true, 1400000000, nil},
+		{"us", 1400000000123456, true, 1400000000, nil},
+		{"ms", 1400000000123, true, 1400000000, nil},
+		{"s", 1400000000, true, 1400000000, nil},
+		{"m", 1000, true, 60000, nil},
+		{"h", 10, true, 36000, nil},
+		{"h", 1 << 62, true, 0, errInfluxInvalidTimestamp},
+	}
+	for _, test := range tests {
+		ts, err := influxTimestamp(influxLine{timestamp: test.timestamp, hasTime: test.hasTime}, test.precision, now)
+		if err != test.err || ts != test.expected {
+			t.Errorf("%d with precision %q: expected %d (%v), got %d (%v)", test.timestamp, test.precision, test.expected, test.err, ts, err)
+		}
+	}
+}
+
+func TestPrepareInfluxIngest(t *testing.T) {
+	if err := ConfigureValidation(ValidationConfig{MaxTags: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureValidation(ValidationConfig{})
+
+	now := time.Unix(1500000000, 0)
+	body := []byte(`# a comment
+cpu,host=a usage=1,idle=2 1400000000
+
+cpu,host=a,dc=b usage=3
+cpu,host=a usage=abc
+mem,host=a used=4,msg="ok"
+`)
+	buf, lineErrors, lines := prepareInfluxIngest(3, body, "s", now, NewBatch())
+
+	if lines != 4 {
+		t.Fatalf("expected 4 lines, got %d", lines)
+	}
+	expected := []struct {
+		name  string
+		value float64
+		time  int64
+	}{
+		{"cpu.usage", 1, 1400000000},
+		{"cpu.idle", 2, 1400000000},
+		{"mem.used", 4, 1500000000},
+	}
+	if len(buf) != len(expected) {
+		t.Fatalf("expected %d metrics, got %+v", len(expected), buf)
+	}
+	for i, md := range buf {
+		e := expected[i]
+		if md.Name != e.name || md.Value != e.value || md.Time != e.time || md.OrgId != 3 || md.Id == "" || md.Mtype != "gauge" {
+			t.Errorf("metric %d: expected %+v, got %+v", i, e, md)
+		}
+		if !reflect.DeepEqual(md.Tags, []string{"host=a"}) {
+			t.Errorf("metric %d: unexpected tags %v", i, md.Tags)
+		}
+	}
+	expectedErrors := []string{
+		"line 4: " + ErrTooManyTags.Error(),
+		"line 5: " + errInfluxInvalidFieldValue.Error(),
+	}
+	if !reflect.DeepEqual(lineErrors, expectedErrors) {
+		t.Errorf("expected errors %q, got %q", expectedErrors, lineErrors)
+	}
+}
+
+func TestInfluxLineErrorsMessage(t *testing.T) {
+	var lineErrors []string
+	for i := 0; i < 12; i++ {
+		lineErrors = append(lineErrors, "line x: invalid field value")
+	}
+	tests := []struct {
+		lineErrors []string
+		lines      int
+		prefix     string
+		suffix     string
+	}{
+		{lineErrors[:1], 2, "partial write: errors encountered on line(s):\nline x", "invalid field value"},
+		{lineErrors[:2], 2, "errors encountered on line(s):\nline x", "invalid field value"},
+		{lineErrors, 20, "partial write: errors encountered on line(s):\n", "invalid field value\nand 2 more"},
+	}
+	for i, test := range tests {
+		msg := influxLineErrorsMessage(test.lineErrors, test.lines)
+		if !strings.HasPrefix(msg, test.prefix) || !strings.HasSuffix(msg, test.suffix) {
+			t.Errorf("test %d: unexpected message %q", i, msg)
+		}
+	}
+	if len(lineErrors) != 12 || lineErrors[10] != "line x: invalid field value" {
+		t.Errorf("the line errors were modified")
+	}
+}
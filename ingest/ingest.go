@@ -1,8 +1,20 @@
 package ingest
 
 import (
+	"net/http"
+
+	"github.com/raintank/tsdb-gw/publish"
 	"github.com/raintank/tsdb-gw/util"
 )
 
 // MetricPool is a shared buffer for metrics ingested over http
 var MetricPool = util.NewMetricDataPool()
+
+// PublishErrorStatus returns the http status code to respond with when publishing failed.
+// If the publisher is overloaded we respond with 503, so clients know to back off and retry.
+func PublishErrorStatus(err error) int {
+	if err == publish.ErrBackpressure {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
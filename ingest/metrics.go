@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"strconv"
 	"sync"
 
@@ -74,7 +73,21 @@ func (dbo discardsByOrg) Add(org int, reason string) {
 	dbo[org] = dbr
 }
 
-func prepareIngest(ctx *models.Context, in []*schema.MetricData, toPublish []*schema.MetricData) ([]*schema.MetricData, MetricsResponse) {
+// Track counts the discards in prometheus
+func (dbo discardsByOrg) Track() {
+	for org, dbr := range dbo {
+		for reason, cnt := range dbr {
+			CountDiscarded(org, reason, cnt)
+		}
+	}
+}
+
+// CountDiscarded counts samples of the org that were discarded for the given reason
+func CountDiscarded(org int, reason string, samples int) {
+	discardedSamples.WithLabelValues(reason, strconv.Itoa(org)).Add(float64(samples))
+}
+
+func prepareIngest(ctx *models.Context, in []*schema.MetricData, toPublish []*schema.MetricData, batch *Batch) ([]*schema.MetricData, MetricsResponse) {
 	resp := NewMetricsResponse()
 	promDiscards := make(discardsByOrg)
 
@@ -90,7 +103,11 @@ func prepareIngest(ctx *models.Context, in []*schema.MetricData, toPublish []*sc
 		if m.Mtype == "" {
 			m.Mtype = "gauge"
 		}
-		if err := m.Validate(); err != nil {
+		err := m.Validate()
+		if err == nil {
+			err = ValidateMetric(m)
+		}
+		if err != nil {
 			log.Debugf("received invalid metric: %v %v %v", m.Name, m.OrgId, m.Tags)
 			resp.AddInvalid(err, i)
 			promDiscards.Add(m.OrgId, err.Error())
@@ -101,6 +118,11 @@ func prepareIngest(ctx *models.Context, in []*schema.MetricData, toPublish []*sc
 		} else {
 			m.SetId()
 		}
+		if err := batch.Add(m); err != nil {
+			resp.AddInvalid(err, i)
+			promDiscards.Add(m.OrgId, err.Error())
+			continue
+		}
 		metricTimestamp.ValueUint32(uint32(m.Time))
 		toPublish = append(toPublish, m)
 	}
@@ -108,11 +130,7 @@ func prepareIngest(ctx *models.Context, in []*schema.MetricData, toPublish []*sc
 	// track invalid/discards in graphite and prometheus
 	metricsRejected.Add(resp.Invalid)
 	metricsValid.Add(len(toPublish))
-	for org, promDiscardsByOrg := range promDiscards {
-		for reason, cnt := range promDiscardsByOrg {
-			discardedSamples.WithLabelValues(reason, strconv.Itoa(org)).Add(float64(cnt))
-		}
-	}
+	promDiscards.Track()
 	return toPublish, resp
 }
 
@@ -141,20 +159,11 @@ func metricsJson(ctx *models.Context) {
 	}
 
 	toPublish := make([]*schema.MetricData, 0, len(metrics))
-	toPublish, resp := prepareIngest(ctx, metrics, toPublish)
-
-	if UseRateLimit() {
-		err = rateLimit(ctx.Req.Context(), ctx.ID, len(toPublish))
-		if err != nil && ctx.Req.Context().Err() == nil {
-			if err == ErrRequestExceedsBurst {
-				ctx.JSON(http.StatusRequestEntityTooLarge, "batch is larger than limit")
-				return
-			}
-
-			// this should only happen if ctx.Req.Context() has a deadline.
-			ctx.JSON(http.StatusTooManyRequests, "rate limit is exhausted")
-			return
-		}
+	batch := NewBatch()
+	toPublish, resp := prepareIngest(ctx, metrics, toPublish, batch)
+
+	if !RateLimitRequest(ctx, len(toPublish)) {
+		return
 	}
 
 	select {
@@ -164,10 +173,12 @@ func metricsJson(ctx *models.Context) {
 	default:
 	}
 
+	batch.Accept()
+	ClampTimestamps(toPublish)
 	err = publish.Publish(toPublish)
 	if err != nil {
 		log.Errorf("failed to publish metrics. %s", err)
-		ctx.JSON(500, err)
+		ctx.JSON(PublishErrorStatus(err), err.Error())
 		return
 	}
 
@@ -216,20 +227,11 @@ func metricsBinary(ctx *models.Context, compressed bool) {
 	}
 
 	toPublish := make([]*schema.MetricData, 0, len(metricData.Metrics))
-	toPublish, resp := prepareIngest(ctx, metricData.Metrics, toPublish)
-
-	if UseRateLimit() {
-		err = rateLimit(ctx.Req.Context(), ctx.ID, len(toPublish))
-		if err != nil && ctx.Req.Context().Err() == nil {
-			if err == ErrRequestExceedsBurst {
-				ctx.JSON(http.StatusRequestEntityTooLarge, "batch is larger than limit")
-				return
-			}
-
-			// this should only happen if ctx.Req.Context() has a deadline.
-			ctx.JSON(http.StatusTooManyRequests, "rate limit is exhausted")
-			return
-		}
+	batch := NewBatch()
+	toPublish, resp := prepareIngest(ctx, metricData.Metrics, toPublish, batch)
+
+	if !RateLimitRequest(ctx, len(toPublish)) {
+		return
 	}
 
 	select {
@@ -239,10 +241,12 @@ func metricsBinary(ctx *models.Context, compressed bool) {
 	default:
 	}
 
+	batch.Accept()
+	ClampTimestamps(toPublish)
 	err = publish.Publish(toPublish)
 	if err != nil {
 		log.Errorf("failed to publish metrics. %s", err)
-		ctx.JSON(500, err)
+		ctx.JSON(PublishErrorStatus(err), err.Error())
 		return
 	}
 
@@ -1,10 +1,15 @@
 package ingest
 
 import (
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"io"
 	"io/ioutil"
+	"math"
+	"net/url"
+	"strconv"
 
 	schema "github.com/grafana/metrictank/schema"
 	"github.com/raintank/tsdb-gw/api/models"
@@ -35,31 +40,25 @@ func OpenTSDBWrite(ctx *models.Context) {
 			return
 		}
 
-		var req OpenTSDBPutRequest
-		err = json.Unmarshal(body, &req)
+		req, err := parseOpenTSDBPutRequest(body)
 		if err != nil {
 			ctx.JSON(400, err.Error())
 			log.Errorf("Read Error, %v", err)
 			return
 		}
 
-		var buf []*schema.MetricData
-		for _, ts := range req {
-			md := MetricPool.Get()
-			*md = schema.MetricData{
-				Name:     ts.Metric,
-				Interval: 0,
-				Value:    ts.Value,
-				Unit:     "unknown",
-				Time:     ts.Timestamp,
-				Mtype:    "gauge",
-				Tags:     ts.FormatTags(md.Tags),
-				OrgId:    ctx.ID,
+		batch := NewBatch()
+		buf, resp := prepareOpenTSDBIngest(ctx.ID, req, ctx.Req.URL.Query(), batch)
+		if !RateLimitRequest(ctx, len(buf)) {
+			for _, m := range buf {
+				m.Tags = m.Tags[:0]
+				MetricPool.Put(m)
 			}
-			md.SetId()
-			buf = append(buf, md)
+			return
 		}
 
+		batch.Accept()
+		ClampTimestamps(buf)
 		err = publish.Publish(buf)
 		for _, m := range buf {
 			m.Tags = m.Tags[:0]
@@ -67,28 +66,179 @@ func OpenTSDBWrite(ctx *models.Context) {
 		}
 		if err != nil {
 			log.Errorf("failed to publish opentsdb write metrics. %s", err)
-			ctx.JSON(500, err)
+			ctx.JSON(PublishErrorStatus(err), err.Error())
 			return
 		}
-		ctx.JSON(200, "ok")
+		resp.send(ctx)
 		return
 	}
 
 	ctx.JSON(400, "no data included in request.")
 }
 
+// OpenTSDBMetric is a data point of a put request. The timestamp is in seconds,
+// or in ms if it has more than 10 digits. The timestamp and the value may be
+// numbers or strings holding numbers.
 type OpenTSDBMetric struct {
 	Metric    string            `json:"metric"`
-	Timestamp int64             `json:"timestamp"`
-	Value     float64           `json:"value"`
+	Timestamp json.RawMessage   `json:"timestamp"`
+	Value     json.RawMessage   `json:"value"`
 	Tags      map[string]string `json:"tags"`
 }
 
 type OpenTSDBPutRequest []OpenTSDBMetric
 
+// OpenTSDBPutSummary is the response to a put request with the summary parameter
+type OpenTSDBPutSummary struct {
+	Failed  int `json:"failed"`
+	Success int `json:"success"`
+}
+
+// OpenTSDBPutDetails is the response to a put request with the details parameter
+type OpenTSDBPutDetails struct {
+	OpenTSDBPutSummary
+	Errors []OpenTSDBPutError `json:"errors"`
+}
+
+type OpenTSDBPutError struct {
+	Datapoint OpenTSDBMetric `json:"datapoint"`
+	Error     string         `json:"error"`
+}
+
+// maxOpenTSDBSeconds is the largest timestamp in seconds, larger ones are in ms
+const maxOpenTSDBSeconds = 9999999999
+
+var (
+	errOpenTSDBInvalidTimestamp = errors.New("invalid timestamp")
+	errOpenTSDBInvalidValue     = errors.New("invalid value")
+)
+
+// parseOpenTSDBPutRequest decodes a put request, which is either a list of data
+// points or a single data point
+func parseOpenTSDBPutRequest(body []byte) (OpenTSDBPutRequest, error) {
+	var req OpenTSDBPutRequest
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '{' {
+		var m OpenTSDBMetric
+		err := json.Unmarshal(body, &m)
+		return OpenTSDBPutRequest{m}, err
+	}
+	err := json.Unmarshal(body, &req)
+	return req, err
+}
+
 func (m OpenTSDBMetric) FormatTags(tagArray []string) []string {
 	for t, v := range m.Tags {
 		tagArray = append(tagArray, t+"="+v)
 	}
 	return tagArray
 }
+
+// timestamp returns the timestamp of the data point in seconds
+func (m OpenTSDBMetric) timestamp() (int64, error) {
+	ts, err := strconv.ParseInt(string(unquoteJSON(m.Timestamp)), 10, 64)
+	if err != nil || ts <= 0 {
+		return 0, errOpenTSDBInvalidTimestamp
+	}
+	if ts > maxOpenTSDBSeconds {
+		ts /= 1000
+	}
+	return ts, nil
+}
+
+func (m OpenTSDBMetric) value() (float64, error) {
+	value, err := strconv.ParseFloat(string(unquoteJSON(m.Value)), 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errOpenTSDBInvalidValue
+	}
+	return value, nil
+}
+
+// unquoteJSON returns the contents of a json string, or the raw json otherwise
+func unquoteJSON(raw json.RawMessage) []byte {
+	if len(raw) >= 2 && raw[0] == '"' && raw[len(raw)-1] == '"' {
+		return raw[1 : len(raw)-1]
+	}
+	return raw
+}
+
+// openTSDBResponse is the outcome of a put request
+type openTSDBResponse struct {
+	details OpenTSDBPutDetails
+	summary bool // respond with the summary, otherwise with nothing when all points succeeded
+	verbose bool // respond with the details
+}
+
+// send responds like OpenTSDB does, with a 400 when any data point failed,
+// even though the valid data points are published.
+func (r openTSDBResponse) send(ctx *models.Context) {
+	status := 200
+	if r.details.Failed > 0 {
+		status = 400
+		if r.details.Success == 0 {
+			r.verbose = true
+		}
+	}
+	switch {
+	case r.verbose:
+		ctx.JSON(status, r.details)
+	case r.summary || r.details.Failed > 0:
+		ctx.JSON(status, r.details.OpenTSDBPutSummary)
+	default:
+		ctx.Resp.WriteHeader(204)
+	}
+}
+
+// prepareOpenTSDBIngest converts the data points of a put request to metrics,
+// and adds them to batch, skipping invalid data points.
+func prepareOpenTSDBIngest(orgId int, req OpenTSDBPutRequest, params url.Values, batch *Batch) ([]*schema.MetricData, openTSDBResponse) {
+	_, summary := params["summary"]
+	_, verbose := params["details"]
+	resp := openTSDBResponse{
+		summary: summary,
+		verbose: verbose,
+		details: OpenTSDBPutDetails{Errors: []OpenTSDBPutError{}},
+	}
+	discards := make(discardsByOrg)
+
+	buf := make([]*schema.MetricData, 0, len(req))
+	for _, dp := range req {
+		ts, err := dp.timestamp()
+		var value float64
+		if err == nil {
+			value, err = dp.value()
+		}
+		var md *schema.MetricData
+		if err == nil {
+			md = MetricPool.Get()
+			*md = schema.MetricData{
+				Name:     dp.Metric,
+				Interval: 0,
+				Value:    value,
+				Unit:     "unknown",
+				Time:     ts,
+				Mtype:    "gauge",
+				Tags:     dp.FormatTags(md.Tags),
+				OrgId:    orgId,
+			}
+			err = prepareMetric(md, batch)
+		}
+		if err != nil {
+			if md != nil {
+				md.Tags = md.Tags[:0]
+				MetricPool.Put(md)
+			}
+			resp.details.Failed++
+			resp.details.Errors = append(resp.details.Errors, OpenTSDBPutError{dp, err.Error()})
+			discards.Add(orgId, err.Error())
+			continue
+		}
+		buf = append(buf, md)
+	}
+	resp.details.Success = len(buf)
+
+	metricsRejected.Add(resp.details.Failed)
+	metricsValid.Add(len(buf))
+	discards.Track()
+	return buf, resp
+}
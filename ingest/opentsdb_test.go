@@ -0,0 +1,69 @@
+package ingest
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	schema "github.com/grafana/metrictank/schema"
+)
+
+func TestPrepareOpenTSDBIngest(t *testing.T) {
+	if err := ConfigureValidation(ValidationConfig{MaxTags: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureValidation(ValidationConfig{})
+
+	now := time.Now().Unix()
+	body := []byte(`[
+		{"metric": "sys.cpu", "timestamp": ` + strconv.FormatInt(now, 10) + `, "value": 1, "tags": {"host": "a"}},
+		{"metric": "sys.cpu", "timestamp": ` + strconv.FormatInt(now*1000+500, 10) + `, "value": "2.5", "tags": {"host": "a"}},
+		{"metric": "sys.cpu", "timestamp": "` + strconv.FormatInt(now, 10) + `", "value": "abc", "tags": {"host": "a"}},
+		{"metric": "sys.cpu", "timestamp": ` + strconv.FormatInt(now, 10) + `, "value": 1, "tags": {"host": "a", "dc": "b"}},
+		{"metric": "sys.cpu", "value": 1, "tags": {"host": "a"}},
+		{"metric": "", "timestamp": ` + strconv.FormatInt(now, 10) + `, "value": 1}
+	]`)
+	req, err := parseOpenTSDBPutRequest(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, resp := prepareOpenTSDBIngest(3, req, url.Values{"details": nil}, NewBatch())
+
+	if len(buf) != 2 || buf[0].Value != 1 || buf[1].Value != 2.5 || buf[1].Time != now {
+		t.Fatalf("unexpected metrics %+v", buf)
+	}
+	for _, md := range buf {
+		if md.OrgId != 3 || md.Id == "" || md.Name != "sys.cpu" || md.Time != now {
+			t.Fatalf("unexpected metric %+v", md)
+		}
+	}
+	if resp.details.Success != 2 || resp.details.Failed != 4 || !resp.verbose || resp.summary {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	expected := []string{errOpenTSDBInvalidValue.Error(), ErrTooManyTags.Error(), errOpenTSDBInvalidTimestamp.Error(), schema.ErrInvalidEmptyName.Error()}
+	for i, e := range resp.details.Errors {
+		if e.Error != expected[i] {
+			t.Errorf("error %d: expected %q, got %q", i, expected[i], e.Error)
+		}
+	}
+	if string(resp.details.Errors[0].Datapoint.Value) != `"abc"` {
+		t.Errorf("expected the failed data point in the error, got %+v", resp.details.Errors[0].Datapoint)
+	}
+}
+
+func TestParseOpenTSDBPutRequest(t *testing.T) {
+	req, err := parseOpenTSDBPutRequest([]byte(` {"metric": "sys.cpu", "timestamp": 1500000000123, "value": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req) != 1 || req[0].Metric != "sys.cpu" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if ts, err := req[0].timestamp(); err != nil || ts != 1500000000 {
+		t.Fatalf("expected timestamp in ms to be converted to seconds, got %d %v", ts, err)
+	}
+	if _, err := parseOpenTSDBPutRequest([]byte(`[{"metric": "sys.cpu"`)); err == nil {
+		t.Fatalf("expected invalid json to be rejected")
+	}
+}
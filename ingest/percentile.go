@@ -0,0 +1,40 @@
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParsePercentiles parses a comma separated list of percentiles, each in (0, 100]
+func ParsePercentiles(str string) ([]float64, error) {
+	var percentiles []float64
+	for _, p := range strings.Split(str, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		percentile, err := strconv.ParseFloat(p, 64)
+		if err != nil || percentile <= 0 || percentile > 100 {
+			return nil, fmt.Errorf("invalid percentile %q", p)
+		}
+		percentiles = append(percentiles, percentile)
+	}
+	return percentiles, nil
+}
+
+// Percentile returns the nearest-rank percentile of the sorted values
+func Percentile(values []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	if rank < 1 {
+		rank = 1
+	}
+	return values[rank-1]
+}
+
+// PercentileName returns the name of the series holding the given percentile
+// of a metric, like <name>.p90 or <name>.p99_9
+func PercentileName(name string, p float64) string {
+	return name + ".p" + strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "_", -1)
+}
@@ -0,0 +1,53 @@
+package ingest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePercentiles(t *testing.T) {
+	tests := []struct {
+		str     string
+		want    []float64
+		wantErr bool
+	}{
+		{str: "90", want: []float64{90}},
+		{str: " 50, 99.9 ,", want: []float64{50, 99.9}},
+		{str: ""},
+		{str: "0", wantErr: true},
+		{str: "101", wantErr: true},
+		{str: "p90", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePercentiles(tt.str)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePercentiles(%q) error = %v, wantErr %v", tt.str, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePercentiles(%q) = %v, want %v", tt.str, got, tt.want)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want float64
+		name string
+	}{
+		{50, 5, "latency.p50"},
+		{90, 9, "latency.p90"},
+		{99.9, 10, "latency.p99_9"},
+		{0.1, 1, "latency.p0_1"},
+	}
+	for _, tt := range tests {
+		if got := Percentile(values, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+		if got := PercentileName("latency", tt.p); got != tt.name {
+			t.Errorf("PercentileName(%v) = %q, want %q", tt.p, got, tt.name)
+		}
+	}
+}
@@ -1,11 +1,17 @@
 package ingest
 
 import (
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
 
-	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	schema "github.com/grafana/metrictank/schema"
+	"github.com/grafana/metrictank/stats"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/raintank/tsdb-gw/api/models"
@@ -13,11 +19,29 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	errPromEmptyName = errors.New("__name__ label can not be empty")
+
+	promHistogramsReceived = stats.NewCounterRate32("metrics.prometheus.histograms")        // native histogram samples received
+	promExemplarsDropped   = stats.NewCounterRate32("metrics.prometheus.exemplars_dropped") // exemplars are not stored
+)
+
+const (
+	promContentTypeV1 = "prometheus.WriteRequest"
+	promContentTypeV2 = "io.prometheus.write.v2.Request"
+)
+
 func PrometheusMTWrite(ctx *models.Context) {
 	if ctx.Req.Request.Body != nil {
 		defer ctx.Req.Request.Body.Close()
-		compressed, err := ioutil.ReadAll(ctx.Req.Request.Body)
 
+		decode, err := promWriteDecoder(ctx.Req.Header.Get("Content-Type"), ctx.Req.Header.Get("Content-Encoding"))
+		if err != nil {
+			ctx.JSON(415, err.Error())
+			return
+		}
+
+		compressed, err := ioutil.ReadAll(ctx.Req.Request.Body)
 		if err != nil {
 			ctx.JSON(400, err.Error())
 			log.Errorf("Read Error, %v", err)
@@ -30,59 +54,218 @@ func PrometheusMTWrite(ctx *models.Context) {
 			return
 		}
 
-		var req prompb.WriteRequest
-		if err := proto.Unmarshal(reqBuf, &req); err != nil {
+		req, err := decode(reqBuf)
+		if err != nil {
 			ctx.JSON(400, err.Error())
 			log.Errorf("Unmarshal Error, %v", err)
 			return
 		}
 
-		buf := make([]*schema.MetricData, 0)
-		for _, ts := range req.Timeseries {
-			var name string
-			var tagSet []string
+		if len(req.metadata) > 0 {
+			promMetadataByOrg.update(ctx.ID, req.metadata, time.Now())
+		}
 
-			for _, l := range ts.Labels {
-				if l.Name == model.MetricNameLabel {
-					name = l.Value
-				} else {
-					tagSet = append(tagSet, l.Name+"="+l.Value)
-				}
-			}
-			if name != "" {
-				for _, sample := range ts.Samples {
-					md := MetricPool.Get()
-					*md = schema.MetricData{
-						Name:     name,
-						Interval: 0,
-						Value:    sample.Value,
-						Unit:     "unknown",
-						Time:     (sample.Timestamp / 1000),
-						Mtype:    "gauge",
-						Tags:     tagSet,
-						OrgId:    ctx.ID,
-					}
-					md.SetId()
-					buf = append(buf, md)
-				}
-			} else {
-				log.Errorf("prometheus metric received with empty name: %v", ts.String())
-				ctx.JSON(400, "invalid metric received: __name__ label can not equal \"\"")
-				return
+		batch := NewBatch()
+		buf, resp, written := preparePromIngest(ctx, req, promMetadataByOrg.get(ctx.ID), batch)
+		if !RateLimitRequest(ctx, len(buf)) {
+			for _, m := range buf {
+				MetricPool.Put(m)
 			}
+			return
 		}
 
+		batch.Accept()
+		ClampTimestamps(buf)
 		err = publish.Publish(buf)
 		for _, m := range buf {
 			MetricPool.Put(m)
 		}
 		if err != nil {
 			log.Errorf("failed to publish prom write metrics. %s", err)
-			ctx.JSON(500, err)
+			ctx.JSON(PublishErrorStatus(err), err.Error())
+			return
+		}
+		written.setHeaders(ctx.Resp.Header())
+		resp.Published = len(buf)
+		if resp.Invalid > 0 {
+			// prometheus doesn't retry a 400, which is what we want: the valid samples were published
+			ctx.JSON(400, resp)
 			return
 		}
-		ctx.JSON(200, "ok")
+		ctx.JSON(200, resp)
 		return
 	}
 	ctx.JSON(400, "no data included in request.")
 }
+
+// promWriteDecoder returns the decoder of the remote write protocol version
+// given by the content type, or an error if the request can't be handled.
+func promWriteDecoder(contentType, contentEncoding string) (func([]byte) (*promWriteRequest, error), error) {
+	if contentEncoding != "" && contentEncoding != "snappy" {
+		return nil, fmt.Errorf("unsupported content encoding %q, only snappy is supported", contentEncoding)
+	}
+	if contentType == "" {
+		return decodePromWriteV1, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "application/x-protobuf" {
+		switch params["proto"] {
+		case "", promContentTypeV1:
+			return decodePromWriteV1, nil
+		case promContentTypeV2:
+			return decodePromWriteV2, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported content type %q, expected application/x-protobuf with proto=%s or proto=%s", contentType, promContentTypeV1, promContentTypeV2)
+}
+
+// promWritten counts what was written, for the response headers of remote write 2.0
+type promWritten struct {
+	samples    int
+	histograms int
+	exemplars  int
+}
+
+func (w promWritten) setHeaders(header http.Header) {
+	header.Set("X-Prometheus-Remote-Write-Samples-Written", strconv.Itoa(w.samples))
+	header.Set("X-Prometheus-Remote-Write-Histograms-Written", strconv.Itoa(w.histograms))
+	header.Set("X-Prometheus-Remote-Write-Exemplars-Written", strconv.Itoa(w.exemplars))
+}
+
+// preparePromIngest converts the samples of a remote write request to metrics,
+// and adds them to batch, skipping invalid samples. Native histogram samples are converted to the
+// series of the equivalent classic histogram, and are skipped as a whole if
+// any of them is invalid. Invalid samples are reported in the response by
+// their index in the request, counting the float samples and then the
+// histogram samples of each series, for all series in order.
+func preparePromIngest(ctx *models.Context, req *promWriteRequest, metadata promMetadata, batch *Batch) ([]*schema.MetricData, MetricsResponse, promWritten) {
+	resp := NewMetricsResponse()
+	discards := make(discardsByOrg)
+	metricTimestamp := getMetricsTimestampStat(ctx.ID)
+	var written promWritten
+
+	buf := make([]*schema.MetricData, 0)
+	index := 0
+	reject := func(err error) {
+		resp.AddInvalid(err, index)
+		discards.Add(ctx.ID, err.Error())
+		index++
+	}
+	for _, ts := range req.series {
+		var name string
+		var tagSet []string
+
+		for _, l := range ts.labels {
+			if l.name == model.MetricNameLabel {
+				name = l.value
+			} else {
+				tagSet = append(tagSet, l.name+"="+l.value)
+			}
+		}
+		promHistogramsReceived.Add(len(ts.histograms))
+		promExemplarsDropped.Add(ts.exemplars)
+		if name == "" {
+			log.Debugf("prometheus metric received with empty name: %v", ts.labels)
+			for i := 0; i < len(ts.samples)+len(ts.histograms); i++ {
+				reject(errPromEmptyName)
+			}
+			continue
+		}
+
+		metricType := ts.metricType
+		if metricType == prompb.MetricMetadata_UNKNOWN {
+			metricType = metadata[name]
+		}
+		mtype := metadata.mtype(name)
+		if ts.metricType != prompb.MetricMetadata_UNKNOWN {
+			mtype = promSeriesMtype(name, ts.metricType)
+		}
+		for _, sample := range ts.samples {
+			md := newPromMetric(ctx.ID, name, tagSet, mtype, sample.value, sample.timestamp)
+			if err := prepareMetric(md, batch); err != nil {
+				log.Debugf("received invalid metric: %v %v %v", md.Name, md.OrgId, md.Tags)
+				MetricPool.Put(md)
+				reject(err)
+				continue
+			}
+			metricTimestamp.ValueUint32(uint32(md.Time))
+			buf = append(buf, md)
+			written.samples++
+			index++
+		}
+
+		for _, h := range ts.histograms {
+			mds, err := promHistogramMetrics(ctx.ID, name, tagSet, metricType, h)
+			for _, md := range mds {
+				if err == nil {
+					err = validateAndSetId(md)
+				}
+			}
+			if err == nil {
+				// the series limits must hold all series of the histogram
+				err = batch.Add(mds...)
+			}
+			if err != nil {
+				log.Debugf("received invalid native histogram: %v %v %v", name, ctx.ID, tagSet)
+				for _, md := range mds {
+					MetricPool.Put(md)
+				}
+				reject(err)
+				continue
+			}
+			for _, md := range mds {
+				metricTimestamp.ValueUint32(uint32(md.Time))
+			}
+			buf = append(buf, mds...)
+			written.histograms++
+			index++
+		}
+	}
+
+	metricsRejected.Add(resp.Invalid)
+	metricsValid.Add(len(buf))
+	discards.Track()
+	return buf, resp, written
+}
+
+func newPromMetric(orgId int, name string, tags []string, mtype string, value float64, timestamp int64) *schema.MetricData {
+	md := MetricPool.Get()
+	*md = schema.MetricData{
+		Name:     name,
+		Interval: 0,
+		Value:    value,
+		Unit:     "unknown",
+		Time:     (timestamp / 1000),
+		Mtype:    mtype,
+		Tags:     tags,
+		OrgId:    orgId,
+	}
+	return md
+}
+
+// promHistogramMetrics converts a native histogram sample to the series of the
+// equivalent classic histogram: <name>_count, <name>_sum and a <name>_bucket
+// series with an le tag for each bucket. metricType is the type of the metric
+// family, if known. The metrics are returned even if err is set.
+func promHistogramMetrics(orgId int, name string, tags []string, metricType prompb.MetricMetadata_MetricType, h promHistogram) ([]*schema.MetricData, error) {
+	buckets, err := h.buckets()
+	if err != nil {
+		return nil, err
+	}
+	mtype := "counter"
+	if h.resetHint == promResetHintGauge || metricType == prompb.MetricMetadata_GAUGEHISTOGRAM {
+		mtype = "gauge"
+	}
+	mds := make([]*schema.MetricData, 0, len(buckets)+2)
+	mds = append(mds,
+		newPromMetric(orgId, name+"_count", tags, mtype, h.count, h.timestamp),
+		newPromMetric(orgId, name+"_sum", tags, mtype, h.sum, h.timestamp),
+	)
+	for _, b := range buckets {
+		bucketTags := make([]string, len(tags), len(tags)+1)
+		copy(bucketTags, tags)
+		bucketTags = append(bucketTags, "le="+formatLe(b.le))
+		mds = append(mds, newPromMetric(orgId, name+"_bucket", bucketTags, mtype, b.count, h.timestamp))
+	}
+	return mds, nil
+}
@@ -0,0 +1,236 @@
+package ingest
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/raintank/tsdb-gw/ingest/writev2"
+)
+
+// promHistogram is a native histogram sample, as sent by remote write 1.0 and
+// 2.0, whose Histogram messages are the same. Spans are runs of consecutive
+// buckets, with the offset of a span relative to the end of the previous one.
+type promHistogram struct {
+	count         float64
+	sum           float64
+	schema        int32
+	zeroThreshold float64
+	zeroCount     float64
+	negativeSpans []promBucketSpan
+	negative      []float64 // absolute counts
+	positiveSpans []promBucketSpan
+	positive      []float64
+	resetHint     uint64
+	timestamp     int64     // in ms
+	customValues  []float64 // upper bounds of the buckets of promHistogramCustomBuckets
+}
+
+type promBucketSpan struct {
+	offset int32
+	length uint32
+}
+
+// promBucket is a bucket of a classic histogram: the number of observations <= le
+type promBucket struct {
+	le    float64
+	count float64
+}
+
+const (
+	promResetHintGauge = 3
+
+	promHistogramMinSchema     = -4
+	promHistogramMaxSchema     = 8
+	promHistogramCustomBuckets = -53
+)
+
+var errPromInvalidHistogram = errors.New("invalid native histogram")
+
+// promHistogramFromV1 converts a native histogram of remote write 1.0
+func promHistogramFromV1(h prompb.Histogram) (promHistogram, error) {
+	ph := promHistogram{
+		sum:           h.Sum,
+		schema:        h.Schema,
+		zeroThreshold: h.ZeroThreshold,
+		resetHint:     uint64(h.ResetHint),
+		timestamp:     h.Timestamp,
+	}
+	switch c := h.Count.(type) {
+	case *prompb.Histogram_CountInt:
+		ph.count = float64(c.CountInt)
+	case *prompb.Histogram_CountFloat:
+		ph.count = c.CountFloat
+	}
+	switch c := h.ZeroCount.(type) {
+	case *prompb.Histogram_ZeroCountInt:
+		ph.zeroCount = float64(c.ZeroCountInt)
+	case *prompb.Histogram_ZeroCountFloat:
+		ph.zeroCount = c.ZeroCountFloat
+	}
+	for _, span := range h.NegativeSpans {
+		ph.negativeSpans = append(ph.negativeSpans, promBucketSpan{span.Offset, span.Length})
+	}
+	for _, span := range h.PositiveSpans {
+		ph.positiveSpans = append(ph.positiveSpans, promBucketSpan{span.Offset, span.Length})
+	}
+	ph.negative = appendDeltaCounts(append([]float64(nil), h.NegativeCounts...), h.NegativeDeltas)
+	ph.positive = appendDeltaCounts(append([]float64(nil), h.PositiveCounts...), h.PositiveDeltas)
+
+	// the vendored prompb predates the custom buckets, whose upper bounds then
+	// end up among the unrecognized fields. The Histogram message of remote
+	// write 2.0 is the same and knows them.
+	if len(h.XXX_unrecognized) > 0 {
+		var v2 writev2.Histogram
+		if err := v2.Unmarshal(h.XXX_unrecognized); err != nil {
+			return ph, err
+		}
+		ph.customValues = v2.CustomValues
+	}
+	return ph, nil
+}
+
+// promHistogramFromV2 converts a native histogram of remote write 2.0
+func promHistogramFromV2(h writev2.Histogram) promHistogram {
+	ph := promHistogram{
+		sum:           h.Sum,
+		schema:        h.Schema,
+		zeroThreshold: h.ZeroThreshold,
+		resetHint:     uint64(h.ResetHint),
+		timestamp:     h.Timestamp,
+		customValues:  h.CustomValues,
+	}
+	switch c := h.Count.(type) {
+	case *writev2.Histogram_CountInt:
+		ph.count = float64(c.CountInt)
+	case *writev2.Histogram_CountFloat:
+		ph.count = c.CountFloat
+	}
+	switch c := h.ZeroCount.(type) {
+	case *writev2.Histogram_ZeroCountInt:
+		ph.zeroCount = float64(c.ZeroCountInt)
+	case *writev2.Histogram_ZeroCountFloat:
+		ph.zeroCount = c.ZeroCountFloat
+	}
+	for _, span := range h.NegativeSpans {
+		ph.negativeSpans = append(ph.negativeSpans, promBucketSpan{span.Offset, span.Length})
+	}
+	for _, span := range h.PositiveSpans {
+		ph.positiveSpans = append(ph.positiveSpans, promBucketSpan{span.Offset, span.Length})
+	}
+	ph.negative = appendDeltaCounts(append([]float64(nil), h.NegativeCounts...), h.NegativeDeltas)
+	ph.positive = appendDeltaCounts(append([]float64(nil), h.PositiveCounts...), h.PositiveDeltas)
+	return ph
+}
+
+// appendDeltaCounts appends the absolute counts of integer histogram buckets,
+// each of which is encoded as the difference with the previous bucket
+func appendDeltaCounts(dst []float64, deltas []int64) []float64 {
+	var count int64
+	for _, delta := range deltas {
+		count += delta
+		dst = append(dst, float64(count))
+	}
+	return dst
+}
+
+// buckets returns the cumulative buckets of the classic histogram equivalent to
+// h, ordered by upper bound and ending with the +Inf bucket.
+func (h promHistogram) buckets() ([]promBucket, error) {
+	var upperBound func(index int32) float64
+	switch {
+	case h.schema == promHistogramCustomBuckets:
+		if len(h.negativeSpans) > 0 {
+			return nil, errPromInvalidHistogram
+		}
+		upperBound = func(index int32) float64 {
+			if index < 0 || int(index) > len(h.customValues) {
+				return math.NaN()
+			}
+			if int(index) == len(h.customValues) {
+				return math.Inf(1)
+			}
+			return h.customValues[index]
+		}
+	case h.schema >= promHistogramMinSchema && h.schema <= promHistogramMaxSchema:
+		// bucket i of schema s holds the observations in (base^(i-1), base^i], with base = 2^(2^-s)
+		upperBound = func(index int32) float64 {
+			if h.schema <= 0 {
+				return math.Ldexp(1, int(index)<<uint(-h.schema))
+			}
+			return math.Exp2(float64(index) / float64(int(1)<<uint(h.schema)))
+		}
+	default:
+		return nil, errPromInvalidHistogram
+	}
+
+	var buckets []promBucket
+	if h.zeroCount > 0 {
+		buckets = append(buckets, promBucket{h.zeroThreshold, h.zeroCount})
+	}
+	positive, err := spanBuckets(h.positiveSpans, h.positive, upperBound)
+	if err != nil {
+		return nil, err
+	}
+	// negative bucket i holds the observations in [-base^i, -base^(i-1))
+	negative, err := spanBuckets(h.negativeSpans, h.negative, func(index int32) float64 {
+		return -upperBound(index - 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	buckets = append(buckets, positive...)
+	buckets = append(buckets, negative...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].le < buckets[j].le })
+
+	// make the counts cumulative, merging buckets with the same upper bound
+	cumulative := buckets[:0]
+	var total float64
+	for _, b := range buckets {
+		total += b.count
+		if n := len(cumulative); n > 0 && cumulative[n-1].le == b.le {
+			cumulative[n-1].count = total
+			continue
+		}
+		cumulative = append(cumulative, promBucket{b.le, total})
+	}
+	if n := len(cumulative); n > 0 && math.IsInf(cumulative[n-1].le, 1) {
+		cumulative = cumulative[:n-1]
+	}
+	return append(cumulative, promBucket{math.Inf(1), h.count}), nil
+}
+
+// spanBuckets returns the buckets described by the spans and their counts
+func spanBuckets(spans []promBucketSpan, counts []float64, upperBound func(index int32) float64) ([]promBucket, error) {
+	var buckets []promBucket
+	var index int32
+	for i, span := range spans {
+		if i == 0 {
+			index = span.offset
+		} else {
+			index += span.offset
+		}
+		for j := uint32(0); j < span.length; j++ {
+			if len(buckets) == len(counts) {
+				return nil, errPromInvalidHistogram
+			}
+			le := upperBound(index)
+			if math.IsNaN(le) {
+				return nil, errPromInvalidHistogram
+			}
+			buckets = append(buckets, promBucket{le, counts[len(buckets)]})
+			index++
+		}
+	}
+	if len(buckets) != len(counts) {
+		return nil, errPromInvalidHistogram
+	}
+	return buckets, nil
+}
+
+// formatLe formats an upper bound as prometheus does for the le label
+func formatLe(le float64) string {
+	return strconv.FormatFloat(le, 'g', -1, 64)
+}
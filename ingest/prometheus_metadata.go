@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// prometheus sends metadata periodically, separately from the samples, so we remember it
+var promMetadataByOrg = newPromMetadataCache(time.Hour)
+
+// promMetadata maps metric family names to their prometheus metric type
+type promMetadata map[string]prompb.MetricMetadata_MetricType
+
+// mtype returns the metrictank metric type of the series with the given name.
+// Histograms and summaries are sent as multiple series: their _bucket, _count
+// and _sum series are counters, the quantiles of summaries are gauges.
+func (p promMetadata) mtype(name string) string {
+	if metricType, ok := p[name]; ok {
+		return promSeriesMtype(name, metricType)
+	}
+	for _, suffix := range promFamilySuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return promSeriesMtype(name, p[strings.TrimSuffix(name, suffix)])
+		}
+	}
+	return "gauge"
+}
+
+var promFamilySuffixes = []string{"_bucket", "_count", "_sum"}
+
+// promSeriesMtype returns the metrictank metric type of a series whose metric
+// family has the given prometheus metric type
+func promSeriesMtype(name string, metricType prompb.MetricMetadata_MetricType) string {
+	switch metricType {
+	case prompb.MetricMetadata_COUNTER:
+		return "counter"
+	case prompb.MetricMetadata_HISTOGRAM, prompb.MetricMetadata_SUMMARY:
+		for _, suffix := range promFamilySuffixes {
+			if strings.HasSuffix(name, suffix) {
+				return "counter"
+			}
+		}
+	}
+	return "gauge"
+}
+
+type orgPromMetadata struct {
+	metadata promMetadata // never modified, replaced on update
+	updated  time.Time
+}
+
+// promMetadataCache holds the metadata of each org. The metadata of orgs that
+// didn't send any for ttl is forgotten.
+type promMetadataCache struct {
+	sync.RWMutex
+	ttl       time.Duration
+	lastPrune time.Time
+	orgs      map[int]orgPromMetadata
+}
+
+func newPromMetadataCache(ttl time.Duration) *promMetadataCache {
+	return &promMetadataCache{
+		ttl:  ttl,
+		orgs: make(map[int]orgPromMetadata),
+	}
+}
+
+// update adds the metadata to that of the org
+func (c *promMetadataCache) update(orgId int, metadata promMetadata, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	current := c.orgs[orgId].metadata
+	merged := make(promMetadata, len(current)+len(metadata))
+	for name, metricType := range current {
+		merged[name] = metricType
+	}
+	for name, metricType := range metadata {
+		merged[name] = metricType
+	}
+	c.orgs[orgId] = orgPromMetadata{merged, now}
+
+	if now.Sub(c.lastPrune) > c.ttl {
+		for id, org := range c.orgs {
+			if now.Sub(org.updated) > c.ttl {
+				delete(c.orgs, id)
+			}
+		}
+		c.lastPrune = now
+	}
+}
+
+// get returns the metadata of the org. It must not be modified
+func (c *promMetadataCache) get(orgId int) promMetadata {
+	c.RLock()
+	defer c.RUnlock()
+	return c.orgs[orgId].metadata
+}
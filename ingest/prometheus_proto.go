@@ -0,0 +1,118 @@
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/raintank/tsdb-gw/ingest/writev2"
+)
+
+// Remote write requests are decoded with the types generated from prometheus'
+// protobuf definitions: prompb for remote write 1.0 and writev2 for 2.0. Both
+// are converted to a promWriteRequest, so the rest of the ingestion doesn't
+// depend on the version of the request.
+
+type promLabel struct {
+	name  string
+	value string
+}
+
+type promSample struct {
+	value     float64
+	timestamp int64 // in ms
+}
+
+type promSeries struct {
+	labels     []promLabel
+	samples    []promSample
+	histograms []promHistogram
+	exemplars  int
+	metricType prompb.MetricMetadata_MetricType // only set by remote write 2.0
+}
+
+type promWriteRequest struct {
+	series   []promSeries
+	metadata promMetadata // only set by remote write 1.0
+}
+
+// decodePromWriteV1 decodes a remote write 1.0 WriteRequest
+func decodePromWriteV1(buf []byte) (*promWriteRequest, error) {
+	var pb prompb.WriteRequest
+	if err := pb.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+
+	req := &promWriteRequest{
+		series: make([]promSeries, 0, len(pb.Timeseries)),
+	}
+	for _, ts := range pb.Timeseries {
+		s := promSeries{
+			labels:    make([]promLabel, 0, len(ts.Labels)),
+			samples:   make([]promSample, 0, len(ts.Samples)),
+			exemplars: len(ts.Exemplars),
+		}
+		for _, l := range ts.Labels {
+			s.labels = append(s.labels, promLabel{l.Name, l.Value})
+		}
+		for _, sample := range ts.Samples {
+			s.samples = append(s.samples, promSample{sample.Value, sample.Timestamp})
+		}
+		for _, h := range ts.Histograms {
+			histogram, err := promHistogramFromV1(h)
+			if err != nil {
+				return nil, err
+			}
+			s.histograms = append(s.histograms, histogram)
+		}
+		req.series = append(req.series, s)
+	}
+	for _, m := range pb.Metadata {
+		if m.MetricFamilyName == "" || m.Type == prompb.MetricMetadata_UNKNOWN {
+			continue
+		}
+		if req.metadata == nil {
+			req.metadata = make(promMetadata)
+		}
+		req.metadata[m.MetricFamilyName] = m.Type
+	}
+	return req, nil
+}
+
+// decodePromWriteV2 decodes a remote write 2.0 Request
+func decodePromWriteV2(buf []byte) (*promWriteRequest, error) {
+	var pb writev2.Request
+	if err := pb.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+
+	req := &promWriteRequest{
+		series: make([]promSeries, 0, len(pb.Timeseries)),
+	}
+	for _, ts := range pb.Timeseries {
+		refs := ts.LabelsRefs
+		if len(refs)%2 != 0 {
+			return nil, fmt.Errorf("odd number of label references")
+		}
+		// the metric types of both versions have the same values
+		s := promSeries{
+			labels:     make([]promLabel, 0, len(refs)/2),
+			samples:    make([]promSample, 0, len(ts.Samples)),
+			exemplars:  len(ts.Exemplars),
+			metricType: prompb.MetricMetadata_MetricType(ts.Metadata.Type),
+		}
+		for i := 0; i < len(refs); i += 2 {
+			if int(refs[i]) >= len(pb.Symbols) || int(refs[i+1]) >= len(pb.Symbols) {
+				return nil, fmt.Errorf("label reference out of range of the %d symbols", len(pb.Symbols))
+			}
+			s.labels = append(s.labels, promLabel{pb.Symbols[refs[i]], pb.Symbols[refs[i+1]]})
+		}
+		for _, sample := range ts.Samples {
+			s.samples = append(s.samples, promSample{sample.Value, sample.Timestamp})
+		}
+		for _, h := range ts.Histograms {
+			s.histograms = append(s.histograms, promHistogramFromV2(h))
+		}
+		req.series = append(req.series, s)
+	}
+	return req, nil
+}
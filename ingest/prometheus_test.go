@@ -0,0 +1,437 @@
+package ingest
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/raintank/tsdb-gw/api/models"
+	"github.com/raintank/tsdb-gw/auth"
+	"github.com/raintank/tsdb-gw/ingest/writev2"
+)
+
+// promHistogramV2 is an integer histogram with a zero bucket, one negative
+// bucket and positive buckets at indexes 0, 1 and 3
+func promHistogramV2(timestamp int64) writev2.Histogram {
+	return writev2.Histogram{
+		Count:          &writev2.Histogram_CountInt{CountInt: 7},
+		Sum:            12.5,
+		ZeroThreshold:  0.001,
+		ZeroCount:      &writev2.Histogram_ZeroCountInt{ZeroCountInt: 2},
+		NegativeSpans:  []writev2.BucketSpan{{Offset: 0, Length: 1}},
+		NegativeDeltas: []int64{1},
+		PositiveSpans:  []writev2.BucketSpan{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+		PositiveDeltas: []int64{1, 1, -1},
+		Timestamp:      timestamp,
+	}
+}
+
+// promHistogramV1 converts a histogram of remote write 2.0 to one of 1.0, as
+// they are encoded the same
+func promHistogramV1(t *testing.T, h writev2.Histogram) prompb.Histogram {
+	buf, err := h.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v1 prompb.Histogram
+	if err := v1.Unmarshal(buf); err != nil {
+		t.Fatal(err)
+	}
+	return v1
+}
+
+func TestDecodePromWriteV1(t *testing.T) {
+	custom := writev2.Histogram{
+		Count:          &writev2.Histogram_CountFloat{CountFloat: 6},
+		Schema:         promHistogramCustomBuckets,
+		PositiveSpans:  []writev2.BucketSpan{{Offset: 0, Length: 3}},
+		PositiveCounts: []float64{1, 2, 3},
+		CustomValues:   []float64{0.1, 1},
+		Timestamp:      3000,
+	}
+	req, err := proto.Marshal(&prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{Labels: []prompb.Label{{Name: "__name__", Value: "up"}}, Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}}},
+			{
+				Labels:     []prompb.Label{{Name: "__name__", Value: "latency"}},
+				Exemplars:  []prompb.Exemplar{{Value: 1}},
+				Histograms: []prompb.Histogram{promHistogramV1(t, promHistogramV2(2000)), promHistogramV1(t, custom)},
+			},
+		},
+		Metadata: []prompb.MetricMetadata{
+			{Type: prompb.MetricMetadata_COUNTER, MetricFamilyName: "requests_total"},
+			{Type: prompb.MetricMetadata_HISTOGRAM, MetricFamilyName: "latency"},
+			{Type: prompb.MetricMetadata_SUMMARY, MetricFamilyName: "gc"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decodePromWriteV1(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.series) != 2 ||
+		decoded.series[0].labels[0] != (promLabel{"__name__", "up"}) ||
+		decoded.series[0].samples[0] != (promSample{1, 1000}) ||
+		len(decoded.series[1].histograms) != 2 ||
+		decoded.series[1].exemplars != 1 {
+		t.Fatalf("unexpected series %+v", decoded.series)
+	}
+	h := decoded.series[1].histograms[0]
+	if h.count != 7 || h.sum != 12.5 || h.zeroCount != 2 || h.timestamp != 2000 ||
+		!reflect.DeepEqual(h.negative, []float64{1}) ||
+		!reflect.DeepEqual(h.positive, []float64{1, 2, 1}) ||
+		!reflect.DeepEqual(h.positiveSpans, []promBucketSpan{{0, 2}, {1, 1}}) {
+		t.Fatalf("unexpected histogram %+v", h)
+	}
+	h = decoded.series[1].histograms[1]
+	if h.count != 6 || h.schema != promHistogramCustomBuckets ||
+		!reflect.DeepEqual(h.positive, []float64{1, 2, 3}) ||
+		!reflect.DeepEqual(h.customValues, []float64{0.1, 1}) {
+		t.Fatalf("unexpected histogram with custom buckets %+v", h)
+	}
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"up", "gauge"},
+		{"requests_total", "counter"},
+		{"latency_bucket", "counter"},
+		{"latency_sum", "counter"},
+		{"latency_count", "counter"},
+		{"gc", "gauge"},
+		{"gc_count", "counter"},
+		{"unknown_count", "gauge"},
+	}
+	for _, tt := range tests {
+		if got := decoded.metadata.mtype(tt.name); got != tt.expected {
+			t.Errorf("mtype(%s) = %s, expected %s", tt.name, got, tt.expected)
+		}
+	}
+
+	if _, err := decodePromWriteV1(req[:len(req)-1]); err == nil {
+		t.Fatalf("expected truncated request to be rejected")
+	}
+	// a label sent as a double
+	if _, err := decodePromWriteV1([]byte{0x0a, 0x0b, 0x0a, 0x09, 0x09, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f}); err == nil {
+		t.Fatalf("expected field of the wrong wire type to be rejected")
+	}
+}
+
+func TestDecodePromWriteV2(t *testing.T) {
+	req, err := proto.Marshal(&writev2.Request{
+		Symbols: []string{"", "__name__", "requests_total", "job", "a"},
+		Timeseries: []writev2.TimeSeries{{
+			LabelsRefs: []uint32{1, 2, 3, 4},
+			Samples:    []writev2.Sample{{Value: 5, Timestamp: 1000}},
+			Histograms: []writev2.Histogram{promHistogramV2(2000)},
+			Exemplars:  []writev2.Exemplar{{Value: 1}},
+			Metadata:   writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_COUNTER},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decodePromWriteV2(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(decoded.series))
+	}
+	s := decoded.series[0]
+	if !reflect.DeepEqual(s.labels, []promLabel{{"__name__", "requests_total"}, {"job", "a"}}) ||
+		!reflect.DeepEqual(s.samples, []promSample{{5, 1000}}) ||
+		len(s.histograms) != 1 || s.histograms[0].count != 7 ||
+		s.exemplars != 1 || s.metricType != prompb.MetricMetadata_COUNTER {
+		t.Fatalf("unexpected series %+v", s)
+	}
+
+	tests := []struct {
+		name string
+		req  writev2.Request
+	}{
+		{
+			name: "label reference out of range",
+			req: writev2.Request{
+				Symbols:    []string{"__name__"},
+				Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{0, 1}}},
+			},
+		},
+		{
+			name: "odd number of label references",
+			req: writev2.Request{
+				Symbols:    []string{"__name__", "up"},
+				Timeseries: []writev2.TimeSeries{{LabelsRefs: []uint32{0, 1, 0}}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		buf, err := tt.req.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodePromWriteV2(buf); err == nil {
+			t.Errorf("%s: expected the request to be rejected", tt.name)
+		}
+	}
+}
+
+func TestPromHistogramBuckets(t *testing.T) {
+	inf := math.Inf(1)
+	tests := []struct {
+		name      string
+		histogram promHistogram
+		expected  []promBucket
+		err       error
+	}{
+		{
+			name: "exponential",
+			histogram: promHistogram{
+				count: 7, zeroThreshold: 0.001, zeroCount: 2,
+				negativeSpans: []promBucketSpan{{0, 1}}, negative: []float64{1},
+				positiveSpans: []promBucketSpan{{0, 2}, {1, 1}}, positive: []float64{1, 2, 1},
+			},
+			expected: []promBucket{{-0.5, 1}, {0.001, 3}, {1, 4}, {2, 6}, {8, 7}, {inf, 7}},
+		},
+		{
+			name: "positive schema",
+			histogram: promHistogram{
+				count: 1, schema: 2,
+				positiveSpans: []promBucketSpan{{-4, 1}}, positive: []float64{1},
+			},
+			expected: []promBucket{{0.5, 1}, {inf, 1}},
+		},
+		{
+			name: "negative schema",
+			histogram: promHistogram{
+				count: 1, schema: -2,
+				positiveSpans: []promBucketSpan{{2, 1}}, positive: []float64{1},
+			},
+			expected: []promBucket{{256, 1}, {inf, 1}},
+		},
+		{
+			name: "custom buckets",
+			histogram: promHistogram{
+				count: 6, schema: promHistogramCustomBuckets, customValues: []float64{0.1, 1},
+				positiveSpans: []promBucketSpan{{0, 3}}, positive: []float64{1, 2, 3},
+			},
+			expected: []promBucket{{0.1, 1}, {1, 3}, {inf, 6}},
+		},
+		{
+			name:      "empty",
+			histogram: promHistogram{},
+			expected:  []promBucket{{inf, 0}},
+		},
+		{
+			name: "missing counts",
+			histogram: promHistogram{
+				positiveSpans: []promBucketSpan{{0, 2}}, positive: []float64{1},
+			},
+			err: errPromInvalidHistogram,
+		},
+		{
+			name:      "invalid schema",
+			histogram: promHistogram{schema: 9},
+			err:       errPromInvalidHistogram,
+		},
+		{
+			name: "custom bucket out of range",
+			histogram: promHistogram{
+				schema: promHistogramCustomBuckets, customValues: []float64{0.1},
+				positiveSpans: []promBucketSpan{{0, 3}}, positive: []float64{1, 2, 3},
+			},
+			err: errPromInvalidHistogram,
+		},
+	}
+	for _, tt := range tests {
+		buckets, err := tt.histogram.buckets()
+		if err != tt.err {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if !reflect.DeepEqual(buckets, tt.expected) {
+			t.Errorf("%s: expected buckets %v, got %v", tt.name, tt.expected, buckets)
+		}
+	}
+}
+
+func TestPromWriteDecoder(t *testing.T) {
+	v1 := reflect.ValueOf(decodePromWriteV1).Pointer()
+	v2 := reflect.ValueOf(decodePromWriteV2).Pointer()
+	tests := []struct {
+		contentType     string
+		contentEncoding string
+		expected        uintptr
+	}{
+		{"", "", v1},
+		{"application/x-protobuf", "snappy", v1},
+		{"application/x-protobuf;proto=prometheus.WriteRequest", "snappy", v1},
+		{"application/x-protobuf; proto=io.prometheus.write.v2.Request", "snappy", v2},
+		{"application/x-protobuf;proto=io.prometheus.write.v3.Request", "snappy", 0},
+		{"application/json", "snappy", 0},
+		{"application/x-protobuf", "zstd", 0},
+	}
+	for _, tt := range tests {
+		decode, err := promWriteDecoder(tt.contentType, tt.contentEncoding)
+		if tt.expected == 0 {
+			if err == nil {
+				t.Errorf("%q %q: expected an error", tt.contentType, tt.contentEncoding)
+			}
+			continue
+		}
+		if err != nil || reflect.ValueOf(decode).Pointer() != tt.expected {
+			t.Errorf("%q %q: unexpected decoder, error %v", tt.contentType, tt.contentEncoding, err)
+		}
+	}
+}
+
+func TestPreparePromIngest(t *testing.T) {
+	if err := ConfigureValidation(ValidationConfig{MaxTags: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureValidation(ValidationConfig{})
+
+	now := time.Now().Unix() * 1000
+	req := &promWriteRequest{
+		series: []promSeries{
+			{
+				labels:  []promLabel{{"__name__", "requests_total"}, {"job", "a"}},
+				samples: []promSample{{1, now}, {2, now + 1000}},
+			},
+			{
+				labels:  []promLabel{{"job", "a"}},
+				samples: []promSample{{1, now}},
+			},
+			{
+				labels:  []promLabel{{"__name__", "up"}, {"job", "a"}, {"dc", "b"}},
+				samples: []promSample{{1, now}},
+			},
+			{
+				labels:  []promLabel{{"__name__", "up"}, {"job", "a"}},
+				samples: []promSample{{1, now}},
+			},
+			{
+				labels: []promLabel{{"__name__", "latency"}},
+				histograms: []promHistogram{
+					{count: 3, sum: 4.5, positiveSpans: []promBucketSpan{{0, 2}}, positive: []float64{1, 2}, timestamp: now},
+					{schema: 9, timestamp: now},
+				},
+				exemplars: 1,
+			},
+			{
+				labels:     []promLabel{{"__name__", "queue_depth"}},
+				histograms: []promHistogram{{count: 1, resetHint: promResetHintGauge, timestamp: now}},
+			},
+		},
+	}
+	ctx := &models.Context{User: &auth.User{ID: 3}}
+	buf, resp, written := preparePromIngest(ctx, req, promMetadata{"requests_total": prompb.MetricMetadata_COUNTER}, NewBatch())
+
+	if len(buf) != 11 || buf[0].Mtype != "counter" || buf[2].Name != "up" || buf[2].Mtype != "gauge" {
+		t.Fatalf("unexpected metrics %+v", buf)
+	}
+	for _, md := range buf {
+		if md.OrgId != 3 || md.Id == "" || md.Interval != 0 {
+			t.Fatalf("unexpected metric %+v", md)
+		}
+	}
+	histogram := []struct {
+		name  string
+		tags  []string
+		value float64
+	}{
+		{"latency_count", nil, 3},
+		{"latency_sum", nil, 4.5},
+		{"latency_bucket", []string{"le=1"}, 1},
+		{"latency_bucket", []string{"le=2"}, 3},
+		{"latency_bucket", []string{"le=+Inf"}, 3},
+	}
+	for i, expected := range histogram {
+		md := buf[3+i]
+		if md.Name != expected.name || !reflect.DeepEqual(md.Tags, expected.tags) || md.Value != expected.value || md.Mtype != "counter" || md.Time != now/1000 {
+			t.Errorf("unexpected histogram metric %d: %+v", i, md)
+		}
+	}
+	if buf[8].Name != "queue_depth_count" || buf[8].Mtype != "gauge" {
+		t.Errorf("expected gauge histogram to be stored as gauges, got %+v", buf[8])
+	}
+
+	if resp.Invalid != 3 ||
+		resp.ValidationErrors[errPromEmptyName.Error()].ExampleIds[0] != 2 ||
+		resp.ValidationErrors[ErrTooManyTags.Error()].ExampleIds[0] != 3 ||
+		resp.ValidationErrors[errPromInvalidHistogram.Error()].ExampleIds[0] != 6 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if written != (promWritten{samples: 3, histograms: 2}) {
+		t.Fatalf("unexpected written counts %+v", written)
+	}
+}
+
+func TestPreparePromIngestSeriesLimit(t *testing.T) {
+	InitSeriesLimits(time.Hour)
+	if err := ConfigureSeriesLimits("3:4", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureSeriesLimits("", "")
+
+	now := time.Now().Unix() * 1000
+	req := &promWriteRequest{
+		series: []promSeries{
+			{
+				labels:     []promLabel{{"__name__", "latency"}},
+				histograms: []promHistogram{{count: 3, sum: 4.5, positiveSpans: []promBucketSpan{{0, 2}}, positive: []float64{1, 2}, timestamp: now}},
+			},
+			{
+				labels:  []promLabel{{"__name__", "up"}},
+				samples: []promSample{{1, now}},
+			},
+		},
+	}
+	ctx := &models.Context{User: &auth.User{ID: 3}}
+	batch := NewBatch()
+	buf, resp, _ := preparePromIngest(ctx, req, nil, batch)
+
+	// the 5 series of the histogram don't fit, so none of them may use up the limit
+	if len(buf) != 1 || buf[0].Name != "up" {
+		t.Fatalf("expected only the float sample to be accepted, got %+v", buf)
+	}
+	if resp.Invalid != 1 || resp.ValidationErrors[ErrSeriesLimitExceeded.Error()].ExampleIds[0] != 0 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	batch.Accept()
+	if count := activeSeries.Count(3); count != 1 {
+		t.Fatalf("expected 1 active series, got %d", count)
+	}
+}
+
+func TestPromMetadataCache(t *testing.T) {
+	cache := newPromMetadataCache(time.Hour)
+	now := time.Unix(100000, 0)
+	cache.update(1, promMetadata{"a": prompb.MetricMetadata_COUNTER, "b": prompb.MetricMetadata_COUNTER}, now)
+	snapshot := cache.get(1)
+	cache.update(1, promMetadata{"b": prompb.MetricMetadata_GAUGE}, now.Add(time.Minute))
+
+	if snapshot.mtype("b") != "counter" {
+		t.Fatalf("expected earlier metadata not to change")
+	}
+	if got := cache.get(1); got.mtype("a") != "counter" || got.mtype("b") != "gauge" {
+		t.Fatalf("expected metadata to be merged, got %v", got)
+	}
+	if cache.get(2) != nil {
+		t.Fatalf("expected no metadata for org 2")
+	}
+
+	// org 1 is forgotten once it hasn't sent metadata for an hour
+	cache.update(2, promMetadata{"a": prompb.MetricMetadata_COUNTER}, now.Add(2*time.Hour))
+	if cache.get(1) != nil || cache.get(2) == nil {
+		t.Fatalf("expected metadata of org 1 to be pruned")
+	}
+}
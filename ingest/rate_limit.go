@@ -4,50 +4,210 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/grafana/metrictank/stats"
+	"github.com/raintank/tsdb-gw/api/models"
 	"golang.org/x/time/rate"
 )
 
 var (
-	rateLimiters map[int]*rate.Limiter // org id -> rate limiter
+	rateLimitersLock sync.RWMutex
+	rateLimits       orgLimitConfig
+	rateLimiters     map[int]*orgRateLimiter // org id -> rate limiter, created on first use
+
+	rateLimitersActive  = stats.NewGauge32("ingest.rate_limit.limiters")
+	rateLimitersEvicted = stats.NewCounterRate32("ingest.rate_limit.limiters_evicted")
 
 	ErrRequestExceedsBurst = errors.New("request exceeds limit burst size")
 )
 
+type orgLimitConfig struct {
+	orgs         map[int]int // org id -> limit
+	defaultLimit int         // limit for orgs not in orgs
+	hasDefault   bool        // without default, orgs not in orgs are unlimited
+}
+
+// limit returns the limit of the org, and false if the org is unlimited
+func (c orgLimitConfig) limit(orgId int) (int, bool) {
+	if limit, ok := c.orgs[orgId]; ok {
+		return limit, true
+	}
+	return c.defaultLimit, c.hasDefault
+}
+
+type orgRateLimiter struct {
+	*rate.Limiter
+	limit    int   // configured limit. the rate may be lower in cluster mode
+	lastUsed int64 // unix timestamp
+	used     int64 // datapoints ingested since the last cluster sync
+	prevUsed int64 // datapoints ingested in the previous cluster sync interval
+}
+
+func (l *orgRateLimiter) consume(datapoints int) {
+	atomic.AddInt64(&l.used, int64(datapoints))
+}
+
+// ConfigureRateLimits sets the per-org rate limits, without tiers or default limit.
 func ConfigureRateLimits(limitStr string) error {
-	if len(limitStr) == 0 {
-		return nil
+	return ConfigureTieredRateLimits(limitStr, "", "")
+}
+
+// ConfigureTieredRateLimits parses the rate limits and starts enforcing them.
+// limitStr is in the format "<orgId>:<limit>;<orgId>:<limit>", tiersStr in the
+// format "<tier>:<limit>;<tier>:<limit>" and defaultStr is the limit for orgs not
+// listed in limitStr. Anywhere a limit is expected, the name of a tier may be
+// used instead. Limits are in datapoints per second.
+// It may be called again to reload the limits: orgs whose limit did not change
+// keep their current limiter, and on error the current limits are kept.
+func ConfigureTieredRateLimits(limitStr, tiersStr, defaultStr string) error {
+	tiers := make(map[string]int)
+	if len(tiersStr) != 0 {
+		for _, tierWithLimit := range strings.Split(tiersStr, ";") {
+			parts := strings.SplitN(tierWithLimit, ":", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return fmt.Errorf("Invalid tier configuration string: %q", tierWithLimit)
+			}
+			limit, err := strconv.ParseInt(parts[1], 10, 32)
+			if err != nil || limit < 0 {
+				return fmt.Errorf("Unable to parse rate limit of tier %q from string: %q", parts[0], parts[1])
+			}
+			tiers[parts[0]] = int(limit)
+		}
+	}
+
+	parseLimit := func(s string) (int, error) {
+		if limit, ok := tiers[s]; ok {
+			return limit, nil
+		}
+		limit, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || limit < 0 {
+			return 0, fmt.Errorf("Unable to parse rate limit from string: %q", s)
+		}
+		return int(limit), nil
+	}
+
+	var config orgLimitConfig
+	var err error
+	if len(defaultStr) != 0 {
+		config.defaultLimit, err = parseLimit(defaultStr)
+		if err != nil {
+			return err
+		}
+		config.hasDefault = true
+	}
+
+	config.orgs, err = parseOrgLimits(limitStr, parseLimit)
+	if err != nil {
+		return err
 	}
 
-	rateLimiters = make(map[int]*rate.Limiter)
+	rateLimitersLock.Lock()
+	rateLimits = config
+	if rateLimiters == nil {
+		rateLimiters = make(map[int]*orgRateLimiter)
+	}
+	rateLimitersLock.Unlock()
+	return nil
+}
 
-	limitsWithOrgs := strings.Split(limitStr, ";")
-	for _, limitWithOrg := range limitsWithOrgs {
+// parseOrgLimits parses limits in the format "<orgId>:<limit>;<orgId>:<limit>"
+func parseOrgLimits(limitStr string, parseLimit func(string) (int, error)) (map[int]int, error) {
+	limits := make(map[int]int)
+	if len(limitStr) == 0 {
+		return limits, nil
+	}
+	for _, limitWithOrg := range strings.Split(limitStr, ";") {
 		limitWithOrgParts := strings.SplitN(limitWithOrg, ":", 2)
 		if len(limitWithOrgParts) != 2 {
-			return fmt.Errorf("Invalid limit configuration string: %q", limitWithOrg)
+			return nil, fmt.Errorf("Invalid limit configuration string: %q", limitWithOrg)
 		}
 
 		orgId, err := strconv.ParseInt(limitWithOrgParts[0], 10, 32)
 		if err != nil {
-			return fmt.Errorf("Unable to parse orgId from string: %q", limitWithOrgParts[0])
+			return nil, fmt.Errorf("Unable to parse orgId from string: %q", limitWithOrgParts[0])
 		}
 
-		limit, err := strconv.ParseInt(limitWithOrgParts[1], 10, 32)
+		limit, err := parseLimit(limitWithOrgParts[1])
 		if err != nil {
-			return fmt.Errorf("Unable to parse rate limit from string: %q", limitWithOrgParts[1])
+			return nil, err
 		}
 
-		rateLimiters[int(orgId)] = rate.NewLimiter(rate.Limit(limit), int(limit))
+		limits[int(orgId)] = limit
 	}
+	return limits, nil
+}
 
-	return nil
+// getRateLimiter returns the limiter of the org, creating it if needed.
+// It returns false if the org is not limited.
+func getRateLimiter(orgId int) (*orgRateLimiter, bool) {
+	now := time.Now().Unix()
+	rateLimitersLock.RLock()
+	limit, limited := rateLimits.limit(orgId)
+	limiter, ok := rateLimiters[orgId]
+	rateLimitersLock.RUnlock()
+	if !limited {
+		return nil, false
+	}
+	if ok && limiter.limit == limit {
+		atomic.StoreInt64(&limiter.lastUsed, now)
+		return limiter, true
+	}
+
+	rateLimitersLock.Lock()
+	defer rateLimitersLock.Unlock()
+	// the limits may have been reloaded or another request may have created
+	// the limiter in the meantime
+	limit, limited = rateLimits.limit(orgId)
+	if !limited {
+		return nil, false
+	}
+	limiter, ok = rateLimiters[orgId]
+	if !ok || limiter.limit != limit {
+		limiter = &orgRateLimiter{
+			Limiter: rate.NewLimiter(rate.Limit(limit), limit),
+			limit:   limit,
+		}
+		rateLimiters[orgId] = limiter
+		rateLimitersActive.Set(len(rateLimiters))
+	}
+	atomic.StoreInt64(&limiter.lastUsed, now)
+	return limiter, true
+}
+
+// EvictIdleRateLimiters periodically removes the limiters of orgs that have not
+// sent data within idleTimeout, so that memory use is bounded by the number of
+// active orgs. Since the bucket of an idle limiter refills within a second,
+// evicting it does not change what the org is allowed to send.
+func EvictIdleRateLimiters(idleTimeout time.Duration) {
+	interval := idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	for now := range time.Tick(interval) {
+		evictIdleRateLimiters(now.Add(-idleTimeout))
+	}
+}
+
+func evictIdleRateLimiters(cutoff time.Time) {
+	rateLimitersLock.Lock()
+	defer rateLimitersLock.Unlock()
+	for orgId, limiter := range rateLimiters {
+		if atomic.LoadInt64(&limiter.lastUsed) < cutoff.Unix() {
+			delete(rateLimiters, orgId)
+			rateLimitersEvicted.Inc()
+		}
+	}
+	rateLimitersActive.Set(len(rateLimiters))
 }
 
 func rateLimit(ctx context.Context, orgId, datapoints int) error {
-	limiter, ok := rateLimiters[orgId]
+	limiter, ok := getRateLimiter(orgId)
 	if !ok {
 		return nil
 	}
@@ -58,18 +218,63 @@ func rateLimit(ctx context.Context, orgId, datapoints int) error {
 		return ErrRequestExceedsBurst
 	}
 	// wait until we are allowed to publish the given number of datapoints
-	return limiter.WaitN(ctx, datapoints)
+	if err := limiter.WaitN(ctx, datapoints); err != nil {
+		return err
+	}
+	limiter.consume(datapoints)
+	return nil
+}
+
+// RateLimitRequest applies the rate limit of the org to an http request that
+// ingests the given number of datapoints, waiting for budget if needed.
+// If the request may not proceed, the response has been written and false
+// is returned. A request whose context is done while waiting is let through,
+// its handler deals with it.
+func RateLimitRequest(ctx *models.Context, datapoints int) bool {
+	err := rateLimit(ctx.Req.Context(), ctx.ID, datapoints)
+	if err == nil || ctx.Req.Context().Err() != nil {
+		return true
+	}
+	if err == ErrRequestExceedsBurst {
+		ctx.JSON(http.StatusRequestEntityTooLarge, "batch is larger than limit")
+		return false
+	}
+	// this should only happen if ctx.Req.Context() has a deadline.
+	ctx.JSON(http.StatusTooManyRequests, "rate limit is exhausted")
+	return false
+}
+
+// AllowDatapoints reports whether the org may ingest the given number of
+// datapoints right now, taking them from its budget if so. Unlike
+// RateLimitRequest it never waits, so it suits inputs that can't hold
+// back a single client without holding back all of them.
+func AllowDatapoints(orgId, datapoints int) bool {
+	limiter, ok := getRateLimiter(orgId)
+	if !ok {
+		return true
+	}
+	if !limiter.AllowN(time.Now(), datapoints) {
+		return false
+	}
+	limiter.consume(datapoints)
+	return true
 }
 
 func IsRateBudgetAvailable(ctx context.Context, orgId int) bool {
-	limiter, ok := rateLimiters[orgId]
+	limiter, ok := getRateLimiter(orgId)
 	if !ok {
 		return true
 	}
 
-	return limiter.Allow()
+	if !limiter.Allow() {
+		return false
+	}
+	limiter.consume(1)
+	return true
 }
 
 func UseRateLimit() bool {
-	return len(rateLimiters) > 0
+	rateLimitersLock.RLock()
+	defer rateLimitersLock.RUnlock()
+	return len(rateLimits.orgs) > 0 || rateLimits.hasDefault
 }
@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/metrictank/stats"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+var (
+	rateLimitSyncs      = stats.NewCounterRate32("ingest.rate_limit.cluster.syncs")
+	rateLimitSyncErrors = stats.NewCounterRate32("ingest.rate_limit.cluster.sync_errors")
+	rateLimitReplicas   = stats.NewGauge32("ingest.rate_limit.cluster.replicas")
+)
+
+// RateLimitBackend is a counter shared by all gateway replicas, used to split the
+// rate limits of orgs between the replicas. Windows are consecutive sync intervals.
+type RateLimitBackend interface {
+	// Report adds the datapoints ingested per org by the replica during the window,
+	// and returns what all replicas ingested during the window before it.
+	Report(report RateLimitReport) (ClusterUsage, error)
+}
+
+// RateLimitReport is the usage of a single replica during a window
+type RateLimitReport struct {
+	Replica string        `json:"replica"`
+	Window  int64         `json:"window"`
+	Usage   map[int]int64 `json:"usage"` // org id -> datapoints
+}
+
+// ClusterUsage is the usage of all replicas during a window
+type ClusterUsage struct {
+	Replicas int           `json:"replicas"`
+	Usage    map[int]int64 `json:"usage"` // org id -> datapoints
+}
+
+type windowUsage struct {
+	replicas map[string]struct{}
+	usage    map[int]int64
+}
+
+// MemoryRateLimitBackend keeps the shared counters in memory. It is used by the
+// gateway that serves the counters to the other replicas, and in tests.
+type MemoryRateLimitBackend struct {
+	sync.Mutex
+	windows map[int64]*windowUsage
+}
+
+func NewMemoryRateLimitBackend() *MemoryRateLimitBackend {
+	return &MemoryRateLimitBackend{
+		windows: make(map[int64]*windowUsage),
+	}
+}
+
+func (m *MemoryRateLimitBackend) Report(report RateLimitReport) (ClusterUsage, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	w, ok := m.windows[report.Window]
+	if !ok {
+		w = &windowUsage{
+			replicas: make(map[string]struct{}),
+			usage:    make(map[int]int64),
+		}
+		m.windows[report.Window] = w
+	}
+	w.replicas[report.Replica] = struct{}{}
+	for orgId, datapoints := range report.Usage {
+		w.usage[orgId] += datapoints
+	}
+
+	// only the current and previous window are still needed
+	for window := range m.windows {
+		if window < report.Window-1 {
+			delete(m.windows, window)
+		}
+	}
+
+	var result ClusterUsage
+	if prev, ok := m.windows[report.Window-1]; ok {
+		result.Replicas = len(prev.replicas)
+		result.Usage = make(map[int]int64, len(prev.usage))
+		for orgId, datapoints := range prev.usage {
+			result.Usage[orgId] = datapoints
+		}
+	}
+	return result, nil
+}
+
+// HTTPRateLimitBackend uses the shared counters served by another gateway
+type HTTPRateLimitBackend struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPRateLimitBackend returns a backend that posts the reports to url,
+// authenticating with apiKey, which must belong to an admin.
+func NewHTTPRateLimitBackend(url, apiKey string, timeout time.Duration) *HTTPRateLimitBackend {
+	return &HTTPRateLimitBackend{
+		url:    url,
+		apiKey: apiKey,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (h *HTTPRateLimitBackend) Report(report RateLimitReport) (ClusterUsage, error) {
+	var result ClusterUsage
+	body, err := json.Marshal(report)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("POST", h.url, bytes.NewReader(body))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("rate limit backend returned status %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
+
+// SyncRateLimits periodically reports the usage of this replica to the backend and
+// splits the rate limit of each org with the other replicas, see clusterShare.
+// Until the first sync, and whenever the backend can't be reached, the current
+// limits are kept.
+func SyncRateLimits(backend RateLimitBackend, replica string, interval time.Duration) {
+	for now := range time.Tick(interval) {
+		syncRateLimits(backend, replica, interval, now)
+	}
+}
+
+func syncRateLimits(backend RateLimitBackend, replica string, interval time.Duration, now time.Time) {
+	rateLimitersLock.RLock()
+	limiters := make(map[int]*orgRateLimiter, len(rateLimiters))
+	for orgId, limiter := range rateLimiters {
+		limiters[orgId] = limiter
+	}
+	rateLimitersLock.RUnlock()
+
+	report := RateLimitReport{
+		Replica: replica,
+		// the usage was collected during the interval that just ended
+		Window: now.UnixNano()/int64(interval) - 1,
+		Usage:  make(map[int]int64, len(limiters)),
+	}
+	used := make(map[int]int64, len(limiters))
+	for orgId, limiter := range limiters {
+		used[orgId] = atomic.SwapInt64(&limiter.used, 0)
+		if used[orgId] > 0 {
+			report.Usage[orgId] = used[orgId]
+		}
+	}
+
+	rateLimitSyncs.Inc()
+	cluster, err := backend.Report(report)
+	if err != nil {
+		rateLimitSyncErrors.Inc()
+		log.Errorf("rate limit: failed to sync with cluster, keeping current limits: %s", err)
+		for orgId, limiter := range limiters {
+			atomic.AddInt64(&limiter.used, used[orgId])
+		}
+		return
+	}
+	if cluster.Replicas == 0 {
+		// no replica reported the previous window, so there is nothing to split yet
+		cluster.Replicas = 1
+	}
+	rateLimitReplicas.Set(cluster.Replicas)
+
+	for orgId, limiter := range limiters {
+		// the cluster usage is that of the window in which this replica used prevUsed
+		own := limiter.prevUsed
+		others := cluster.Usage[orgId] - own
+		if others < 0 {
+			others = 0
+		}
+		limiter.prevUsed = used[orgId]
+		share := clusterShare(limiter.limit, cluster.Replicas, float64(own)/interval.Seconds(), float64(others)/interval.Seconds())
+		// the burst stays the configured one, so that requests the org may send
+		// to a single gateway aren't rejected by one of the replicas
+		limiter.SetLimit(rate.Limit(share))
+	}
+}
+
+// clusterSpare is the part of the limit of an org that is split evenly between
+// the replicas even when the org used all of it, so that replicas that didn't
+// get any data of the org can still ingest some.
+const clusterSpare = 0.01
+
+// clusterShare returns the rate this replica may use of limit, given the rates at
+// which it and the other replicas ingested during the last window. Each replica
+// keeps what it used and the rest of the limit is split evenly, so that the
+// shares of all replicas add up to the limit. If the replicas used more than
+// the limit minus clusterSpare, their usage is scaled down to fit.
+func clusterShare(limit, replicas int, ownRate, othersRate float64) float64 {
+	total := ownRate + othersRate
+	spare := float64(limit) - total
+	if min := clusterSpare * float64(limit); spare < min {
+		ownRate *= (float64(limit) - min) / total
+		spare = min
+	}
+	return ownRate + spare/float64(replicas)
+}
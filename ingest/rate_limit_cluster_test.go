@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestMemoryRateLimitBackend(t *testing.T) {
+	backend := NewMemoryRateLimitBackend()
+	backend.Report(RateLimitReport{Replica: "a", Window: 10, Usage: map[int]int64{1: 100, 2: 5}})
+	backend.Report(RateLimitReport{Replica: "b", Window: 10, Usage: map[int]int64{1: 50}})
+
+	usage, _ := backend.Report(RateLimitReport{Replica: "a", Window: 11, Usage: map[int]int64{1: 1}})
+	if usage.Replicas != 2 || usage.Usage[1] != 150 || usage.Usage[2] != 5 {
+		t.Fatalf("unexpected usage of window 10: %+v", usage)
+	}
+
+	// windows before the previous one are dropped
+	backend.Report(RateLimitReport{Replica: "a", Window: 13})
+	if _, ok := backend.windows[10]; ok {
+		t.Fatalf("expected window 10 to be dropped")
+	}
+	usage, _ = backend.Report(RateLimitReport{Replica: "a", Window: 14})
+	if usage.Replicas != 1 || len(usage.Usage) != 0 {
+		t.Fatalf("unexpected usage of window 13: %+v", usage)
+	}
+}
+
+func Test_clusterShare(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		replicas   int
+		ownRate    float64
+		othersRate float64
+		expected   float64
+	}{
+		{"single_replica", 1000, 1, 0, 0, 1000},
+		{"all_idle", 1000, 4, 0, 0, 250},
+		{"others_idle", 1000, 4, 400, 0, 550},
+		{"others_use_some", 1000, 2, 100, 300, 400},
+		{"others_use_all", 1000, 2, 0, 1000, 5},
+		{"over_limit", 1000, 2, 1000, 1000, 500},
+	}
+	for _, tt := range tests {
+		if got := clusterShare(tt.limit, tt.replicas, tt.ownRate, tt.othersRate); got != tt.expected {
+			t.Errorf("%s: clusterShare() = %f, expected %f", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// TestClusterShares simulates replicas that ingest what they are sent, up to
+// their share, and checks that together they stay within the limit.
+func TestClusterShares(t *testing.T) {
+	const limit = 1000
+	tests := []struct {
+		name   string
+		demand [][]float64 // per phase, the rate each replica is sent
+	}{
+		{"single_replica", [][]float64{{2000, 0, 0, 0}}},
+		{"even", [][]float64{{500, 500, 500, 500}}},
+		{"uneven", [][]float64{{800, 100, 50, 0}, {0, 0, 0, 3000}}},
+		{"shift", [][]float64{{3000, 0, 0, 0}, {0, 3000, 0, 0}}},
+	}
+	for _, tt := range tests {
+		replicas := len(tt.demand[0])
+		used := make([]float64, replicas)
+		for _, demand := range tt.demand {
+			for window := 0; window < 200; window++ {
+				var total float64
+				for _, u := range used {
+					total += u
+				}
+				var shares float64
+				next := make([]float64, replicas)
+				for i := range used {
+					share := clusterShare(limit, replicas, used[i], total-used[i])
+					shares += share
+					next[i] = math.Min(demand[i], share)
+				}
+				if shares > limit*1.000001 {
+					t.Fatalf("%s: the shares of window %d add up to %f, over the limit", tt.name, window, shares)
+				}
+				used = next
+			}
+		}
+		// the last phase converged: the demand is served up to the limit
+		var total, demand float64
+		for i, u := range used {
+			total += u
+			demand += tt.demand[len(tt.demand)-1][i]
+		}
+		if expected := math.Min(demand, limit*(1-clusterSpare)); total < expected*0.99 {
+			t.Errorf("%s: the replicas ingest %f, expected about %f", tt.name, total, expected)
+		}
+	}
+}
+
+type failingRateLimitBackend struct{}
+
+func (failingRateLimitBackend) Report(report RateLimitReport) (ClusterUsage, error) {
+	return ClusterUsage{}, errors.New("unreachable")
+}
+
+func TestSyncRateLimits(t *testing.T) {
+	if err := ConfigureRateLimits("1:1000"); err != nil {
+		t.Fatal(err)
+	}
+	limiter, _ := getRateLimiter(1)
+	backend := NewMemoryRateLimitBackend()
+	interval := time.Second
+	now := time.Unix(100, 0)
+
+	// another replica used 300 datapoints/s of org 1, this one 100
+	backend.Report(RateLimitReport{Replica: "other", Window: 98, Usage: map[int]int64{1: 300}})
+	limiter.used = 100
+	syncRateLimits(backend, "self", interval, now.Add(-interval))
+	backend.Report(RateLimitReport{Replica: "other", Window: 99, Usage: map[int]int64{1: 300}})
+	syncRateLimits(backend, "self", interval, now)
+	if limiter.Limit() != rate.Limit(400) || limiter.Burst() != 1000 {
+		t.Fatalf("expected limit of 400 and burst of 1000, got %f and %d", float64(limiter.Limit()), limiter.Burst())
+	}
+
+	// a busy cluster still leaves this replica its share of the spare
+	backend.Report(RateLimitReport{Replica: "other", Window: 100, Usage: map[int]int64{1: 5000}})
+	syncRateLimits(backend, "self", interval, now.Add(interval))
+	syncRateLimits(backend, "self", interval, now.Add(2*interval))
+	if limiter.Limit() != rate.Limit(5) || limiter.Burst() != 1000 {
+		t.Fatalf("expected limit of 5 and burst of 1000, got %f and %d", float64(limiter.Limit()), limiter.Burst())
+	}
+
+	// when the backend fails, the limit and the usage to report are kept
+	limiter.used = 42
+	syncRateLimits(failingRateLimitBackend{}, "self", interval, now.Add(3*interval))
+	if limiter.Limit() != rate.Limit(5) || limiter.used != 42 {
+		t.Fatalf("expected limit and usage to be kept, got %f and %d", float64(limiter.Limit()), limiter.used)
+	}
+}
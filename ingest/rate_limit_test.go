@@ -49,7 +49,7 @@ func TestConfigureRateLimits(t *testing.T) {
 			t.Errorf("%s: ConfigureRateLimits() error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
 		for orgId, expectedLimit := range tt.expectedLimiters {
-			if limiter, ok := rateLimiters[orgId]; !ok {
+			if limiter, ok := getRateLimiter(orgId); !ok {
 				t.Fatalf("%s: Expected limit for org %d, but there was none", tt.name, orgId)
 			} else {
 				if limiter.Limit() != rate.Limit(expectedLimit) {
@@ -60,6 +60,149 @@ func TestConfigureRateLimits(t *testing.T) {
 	}
 }
 
+func TestReloadRateLimits(t *testing.T) {
+	if err := ConfigureRateLimits("1:100;2:200"); err != nil {
+		t.Fatalf("ConfigureRateLimits() returned error: %s", err)
+	}
+	limiter1, _ := getRateLimiter(1)
+	limiter2, _ := getRateLimiter(2)
+
+	// a failed reload must keep the current limits
+	if err := ConfigureRateLimits("1:100;2:foo"); err == nil {
+		t.Fatalf("expected ConfigureRateLimits() to fail")
+	}
+	if l, _ := getRateLimiter(2); l != limiter2 {
+		t.Fatalf("expected limits to be kept after failed reload")
+	}
+
+	// unchanged limits keep their limiter, changed and removed ones don't
+	if err := ConfigureRateLimits("1:100;3:300"); err != nil {
+		t.Fatalf("ConfigureRateLimits() returned error: %s", err)
+	}
+	if l, _ := getRateLimiter(1); l != limiter1 {
+		t.Fatalf("expected limiter of org 1 to be kept")
+	}
+	if _, ok := getRateLimiter(2); ok {
+		t.Fatalf("expected limiter of org 2 to be removed")
+	}
+	if l, ok := getRateLimiter(3); !ok || l.Limit() != 300 {
+		t.Fatalf("expected limiter of org 3 with limit 300")
+	}
+
+	if err := ConfigureRateLimits(""); err != nil {
+		t.Fatalf("ConfigureRateLimits() returned error: %s", err)
+	}
+	if UseRateLimit() {
+		t.Fatalf("expected all limits to be removed")
+	}
+}
+
+func TestTieredRateLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		limits   string
+		tiers    string
+		def      string
+		wantErr  bool
+		expected map[int]int // org id -> limit, -1 means unlimited
+	}{
+		{
+			name:     "no default",
+			limits:   "1:100",
+			expected: map[int]int{1: 100, 2: -1},
+		},
+		{
+			name:     "numeric default",
+			limits:   "1:100",
+			def:      "50",
+			expected: map[int]int{1: 100, 2: 50, 3: 50},
+		},
+		{
+			name:     "tiers",
+			limits:   "1:pro;2:enterprise;3:5",
+			tiers:    "free:10;pro:100;enterprise:1000",
+			def:      "free",
+			expected: map[int]int{1: 100, 2: 1000, 3: 5, 4: 10},
+		},
+		{
+			name:    "unknown tier",
+			limits:  "1:gold",
+			tiers:   "free:10",
+			wantErr: true,
+		},
+		{
+			name:    "invalid tier",
+			tiers:   "free",
+			wantErr: true,
+		},
+		{
+			name:    "invalid default",
+			def:     "-5",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ConfigureRateLimits("")
+		err := ConfigureTieredRateLimits(tt.limits, tt.tiers, tt.def)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: ConfigureTieredRateLimits() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		for orgId, expected := range tt.expected {
+			limiter, ok := getRateLimiter(orgId)
+			if expected == -1 {
+				if ok {
+					t.Fatalf("%s: expected org %d to be unlimited", tt.name, orgId)
+				}
+				continue
+			}
+			if !ok || limiter.Limit() != rate.Limit(expected) {
+				t.Fatalf("%s: expected org %d to have limit %d", tt.name, orgId, expected)
+			}
+		}
+	}
+}
+
+func TestEvictIdleRateLimiters(t *testing.T) {
+	if err := ConfigureTieredRateLimits("", "", "100"); err != nil {
+		t.Fatal(err)
+	}
+	for orgId := 1; orgId <= 3; orgId++ {
+		getRateLimiter(orgId)
+	}
+	rateLimitersLock.Lock()
+	rateLimiters[1].lastUsed = time.Now().Add(-time.Hour).Unix()
+	rateLimitersLock.Unlock()
+
+	evictIdleRateLimiters(time.Now().Add(-time.Minute))
+
+	rateLimitersLock.RLock()
+	_, ok1 := rateLimiters[1]
+	_, ok2 := rateLimiters[2]
+	rateLimitersLock.RUnlock()
+	if ok1 || !ok2 {
+		t.Fatalf("expected only the idle limiter to be evicted")
+	}
+	// an evicted limiter is recreated on use
+	if _, ok := getRateLimiter(1); !ok {
+		t.Fatalf("expected limiter of org 1 to be recreated")
+	}
+}
+
+func TestAllowDatapoints(t *testing.T) {
+	if err := ConfigureRateLimits("1:10"); err != nil {
+		t.Fatal(err)
+	}
+	if !AllowDatapoints(2, 1000) {
+		t.Fatalf("expected unlimited org to be allowed")
+	}
+	if !AllowDatapoints(1, 10) {
+		t.Fatalf("expected org 1 to be allowed its full burst")
+	}
+	if AllowDatapoints(1, 5) {
+		t.Fatalf("expected org 1 to be rejected after exhausting its budget")
+	}
+}
+
 // TestLimitingRate is a bit racy, but since it tests a rate limiter I can't think of a better way to do it.
 // In this test we're testing the limiter with a defined number of requests per time and a defined rate limit,
 // then we check if the expected number of requests has been accepted / rejected. But even if theoretically
@@ -0,0 +1,207 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/metrictank/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/raintank/tsdb-gw/publish/kafka/keycache"
+)
+
+var (
+	seriesLimitsLock sync.RWMutex
+	seriesLimits     orgLimitConfig
+	activeSeries     *keycache.KeyCache // series seen within the window, of orgs with a series limit
+
+	ErrSeriesLimitExceeded = errors.New("active series limit exceeded")
+
+	activeSeriesGauge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "gateway",
+			Name:      "active_series",
+			Help:      "The number of series that received data within the series limit window, for orgs with a series limit.",
+		},
+		[]string{"org"},
+	)
+)
+
+// InitSeriesLimits starts tracking the active series of orgs with a series limit.
+// A series is active from when it receives data until up to window later.
+func InitSeriesLimits(window time.Duration) {
+	activeSeries = keycache.NewKeyCache(window)
+	go reportActiveSeries(activeSeries, 10*time.Second)
+}
+
+// ConfigureSeriesLimits parses the series limits and starts enforcing them.
+// limitStr is in the format "<orgId>:<limit>;<orgId>:<limit>" and defaultStr is
+// the limit for orgs not listed in limitStr. On error the current limits are kept.
+func ConfigureSeriesLimits(limitStr, defaultStr string) error {
+	parseLimit := func(s string) (int, error) {
+		limit, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || limit < 0 {
+			return 0, fmt.Errorf("Unable to parse series limit from string: %q", s)
+		}
+		return int(limit), nil
+	}
+
+	var config orgLimitConfig
+	var err error
+	if len(defaultStr) != 0 {
+		config.defaultLimit, err = parseLimit(defaultStr)
+		if err != nil {
+			return err
+		}
+		config.hasDefault = true
+	}
+	config.orgs, err = parseOrgLimits(limitStr, parseLimit)
+	if err != nil {
+		return err
+	}
+
+	seriesLimitsLock.Lock()
+	seriesLimits = config
+	seriesLimitsLock.Unlock()
+	return nil
+}
+
+// CheckSeriesLimit marks the series of the metric as active, and returns
+// ErrSeriesLimitExceeded if it is a new series and its org already has as many
+// active series as its limit allows. The id of the metric must be set.
+func CheckSeriesLimit(md *schema.MetricData) error {
+	if activeSeries == nil {
+		return nil
+	}
+	limit, limited := seriesLimit(md.OrgId)
+	if !limited {
+		return nil
+	}
+	if limit == 0 {
+		return ErrSeriesLimitExceeded
+	}
+	mkey, err := schema.MKeyFromString(md.Id)
+	if err != nil {
+		// metrics with invalid ids are rejected by the validation
+		return nil
+	}
+	if _, ok := activeSeries.TouchLimited(mkey, limit); !ok {
+		return ErrSeriesLimitExceeded
+	}
+	return nil
+}
+
+// FilterSeriesLimit removes the metrics rejected by CheckSeriesLimit from buf
+// and counts them as discarded. It returns the remaining metrics, and the number
+// of metrics removed. buf itself is not modified.
+func FilterSeriesLimit(buf []*schema.MetricData) ([]*schema.MetricData, int) {
+	var kept []*schema.MetricData
+	discards := make(discardsByOrg)
+	for i, md := range buf {
+		if err := CheckSeriesLimit(md); err != nil {
+			if kept == nil {
+				kept = make([]*schema.MetricData, i, len(buf))
+				copy(kept, buf[:i])
+			}
+			discards.Add(md.OrgId, err.Error())
+			continue
+		}
+		if kept != nil {
+			kept = append(kept, md)
+		}
+	}
+	if kept == nil {
+		return buf, 0
+	}
+	discards.Track()
+	return kept, len(buf) - len(kept)
+}
+
+// Batch applies the series limits to the metrics of a request. Their series
+// only become active once the request is accepted and Accept is called, so
+// that requests rejected as a whole, e.g. by the rate limits, don't use up the
+// series limits.
+// Concurrent requests may together take an org slightly over its limit.
+// A Batch is not safe for concurrent use.
+type Batch struct {
+	series    map[schema.MKey]struct{} // new series of orgs with a series limit
+	newSeries map[uint32]int           // number of new series per org
+}
+
+// NewBatch returns an empty Batch
+func NewBatch() *Batch {
+	return &Batch{
+		series:    make(map[schema.MKey]struct{}),
+		newSeries: make(map[uint32]int),
+	}
+}
+
+// Add adds the metrics to the batch, and returns ErrSeriesLimitExceeded if
+// their new series, with those already in the batch, take an org over its
+// limit. Then none of the metrics are added, so that the series of the
+// samples of a line or a histogram are accepted or rejected together.
+// The ids of the metrics must be set.
+func (b *Batch) Add(mds ...*schema.MetricData) error {
+	if activeSeries == nil {
+		return nil
+	}
+	var added []schema.MKey
+	for _, md := range mds {
+		limit, limited := seriesLimit(md.OrgId)
+		if !limited {
+			continue
+		}
+		mkey, err := schema.MKeyFromString(md.Id)
+		if err != nil {
+			// metrics with invalid ids are rejected by the validation
+			continue
+		}
+		if _, ok := b.series[mkey]; ok || (limit > 0 && activeSeries.Seen(mkey)) {
+			continue
+		}
+		b.series[mkey] = struct{}{}
+		b.newSeries[mkey.Org]++
+		added = append(added, mkey)
+		if limit == 0 || activeSeries.Count(mkey.Org)+b.newSeries[mkey.Org] > limit {
+			for _, mkey := range added {
+				delete(b.series, mkey)
+				b.newSeries[mkey.Org]--
+			}
+			return ErrSeriesLimitExceeded
+		}
+	}
+	return nil
+}
+
+// Accept marks the new series of the batch as active
+func (b *Batch) Accept() {
+	for mkey := range b.series {
+		activeSeries.Touch(mkey)
+	}
+}
+
+func seriesLimit(orgId int) (int, bool) {
+	seriesLimitsLock.RLock()
+	defer seriesLimitsLock.RUnlock()
+	return seriesLimits.limit(orgId)
+}
+
+func reportActiveSeries(series *keycache.KeyCache, interval time.Duration) {
+	reported := make(map[uint32]struct{})
+	for range time.Tick(interval) {
+		counts := series.OrgCounts()
+		for org := range reported {
+			if _, ok := counts[org]; !ok {
+				activeSeriesGauge.DeleteLabelValues(strconv.Itoa(int(org)))
+				delete(reported, org)
+			}
+		}
+		for org, count := range counts {
+			activeSeriesGauge.WithLabelValues(strconv.Itoa(int(org))).Set(float64(count))
+			reported[org] = struct{}{}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/metrictank/schema"
+)
+
+func newSeries(orgId int, name string) *schema.MetricData {
+	md := &schema.MetricData{
+		OrgId:    orgId,
+		Name:     name,
+		Interval: 10,
+		Mtype:    "gauge",
+		Time:     1,
+	}
+	md.SetId()
+	return md
+}
+
+func TestCheckSeriesLimit(t *testing.T) {
+	InitSeriesLimits(time.Hour)
+	if err := ConfigureSeriesLimits("1:2;2:0", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureSeriesLimits("", "")
+
+	tests := []struct {
+		orgId    int
+		name     string
+		expected error
+	}{
+		{1, "a", nil},
+		{1, "b", nil},
+		{1, "a", nil},                    // known series are still accepted
+		{1, "c", ErrSeriesLimitExceeded}, // a third series is not
+		{2, "a", ErrSeriesLimitExceeded},
+		{3, "a", nil},
+		{3, "b", nil},
+		{3, "c", nil},
+	}
+	for _, tt := range tests {
+		if err := CheckSeriesLimit(newSeries(tt.orgId, tt.name)); err != tt.expected {
+			t.Errorf("CheckSeriesLimit(%d, %s) = %v, expected %v", tt.orgId, tt.name, err, tt.expected)
+		}
+	}
+
+	// orgs without a limit are not tracked
+	counts := activeSeries.OrgCounts()
+	if len(counts) != 1 || counts[1] != 2 {
+		t.Fatalf("expected only org 1 to have 2 active series, got %v", counts)
+	}
+
+	// a default limit applies to all other orgs
+	if err := ConfigureSeriesLimits("1:2", "3"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		err := CheckSeriesLimit(newSeries(4, fmt.Sprintf("s%d", i)))
+		if (i < 3) != (err == nil) {
+			t.Errorf("series %d of org 4: unexpected error %v", i, err)
+		}
+	}
+
+	if err := ConfigureSeriesLimits("1:two", ""); err == nil {
+		t.Fatalf("expected invalid limit to be rejected")
+	}
+}
+
+func TestFilterSeriesLimit(t *testing.T) {
+	InitSeriesLimits(time.Hour)
+	if err := ConfigureSeriesLimits("1:1", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureSeriesLimits("", "")
+
+	buf := []*schema.MetricData{newSeries(2, "a"), newSeries(2, "b")}
+	kept, rejected := FilterSeriesLimit(buf)
+	if rejected != 0 || len(kept) != 2 {
+		t.Fatalf("expected all metrics to be kept, got %d kept and %d rejected", len(kept), rejected)
+	}
+
+	buf = []*schema.MetricData{newSeries(1, "a"), newSeries(1, "b"), newSeries(2, "c"), newSeries(1, "a")}
+	kept, rejected = FilterSeriesLimit(buf)
+	if rejected != 1 || len(kept) != 3 || kept[1] != buf[2] {
+		t.Fatalf("expected the metric of series b to be rejected, got %d kept and %d rejected", len(kept), rejected)
+	}
+	if buf[1].Name != "b" {
+		t.Fatalf("expected buf not to be modified")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	InitSeriesLimits(time.Hour)
+	if err := ConfigureSeriesLimits("1:2;2:0", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureSeriesLimits("", "")
+
+	tests := []struct {
+		orgId    int
+		names    []string
+		expected error
+	}{
+		{1, []string{"a"}, nil},
+		{1, []string{"a"}, nil},
+		{1, []string{"b", "c"}, ErrSeriesLimitExceeded}, // the series of one Add are rejected together
+		{1, []string{"b"}, nil},
+		{1, []string{"c"}, ErrSeriesLimitExceeded},
+		{1, []string{"a", "b"}, nil},
+		{2, []string{"a"}, ErrSeriesLimitExceeded},
+		{3, []string{"a", "b", "c"}, nil},
+	}
+	batch := NewBatch()
+	for _, tt := range tests {
+		var mds []*schema.MetricData
+		for _, name := range tt.names {
+			mds = append(mds, newSeries(tt.orgId, name))
+		}
+		if err := batch.Add(mds...); err != tt.expected {
+			t.Errorf("Add(%d, %v) = %v, expected %v", tt.orgId, tt.names, err, tt.expected)
+		}
+	}
+
+	// the series only become active once the batch is accepted
+	if counts := activeSeries.OrgCounts(); len(counts) != 0 {
+		t.Fatalf("expected no active series before Accept, got %v", counts)
+	}
+	batch.Accept()
+	counts := activeSeries.OrgCounts()
+	if len(counts) != 1 || counts[1] != 2 {
+		t.Fatalf("expected only org 1 to have 2 active series, got %v", counts)
+	}
+
+	// active series count against the limit of later batches
+	batch = NewBatch()
+	if err := batch.Add(newSeries(1, "a"), newSeries(1, "b")); err != nil {
+		t.Errorf("expected active series to be accepted, got %v", err)
+	}
+	if err := batch.Add(newSeries(1, "c")); err != ErrSeriesLimitExceeded {
+		t.Errorf("expected a third series to be rejected, got %v", err)
+	}
+
+}
@@ -0,0 +1,170 @@
+package statsd
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/metrictank/schema"
+	"github.com/raintank/tsdb-gw/ingest"
+)
+
+// aggregate holds the samples of a series received during a flush interval
+type aggregate struct {
+	name  string
+	tags  []string
+	mtype string
+
+	count   float64 // counters: the sum of the values, timers: the number of values. Both corrected for the sample rates
+	gauge   float64
+	updated bool // whether the gauge was set during the interval
+
+	// timers
+	seen         int // the number of values received
+	sum          float64
+	lower, upper float64
+	values       []float64 // a random sample of at most maxValues of the values, for the percentiles
+
+	set map[string]struct{} // at most maxValues members
+}
+
+// aggregator aggregates the samples of each org per flush interval
+type aggregator struct {
+	sync.Mutex
+	orgs        map[int]map[string]*aggregate
+	percentiles []float64
+	maxSeries   int // per org and interval
+	maxValues   int // per timer and set
+}
+
+func newAggregator(percentiles []float64, maxSeries, maxValues int) *aggregator {
+	return &aggregator{
+		orgs:        make(map[int]map[string]*aggregate),
+		percentiles: percentiles,
+		maxSeries:   maxSeries,
+		maxValues:   maxValues,
+	}
+}
+
+// add adds the sample to its aggregate. It returns false if the sample is
+// dropped because its org already has maxSeries aggregates in this interval.
+func (a *aggregator) add(orgId int, s sample) bool {
+	key := s.mtype + "|" + s.name + ";" + strings.Join(s.tags, ";")
+	a.Lock()
+	defer a.Unlock()
+	series, ok := a.orgs[orgId]
+	if !ok {
+		series = make(map[string]*aggregate)
+		a.orgs[orgId] = series
+	}
+	agg, ok := series[key]
+	if !ok {
+		if len(series) >= a.maxSeries {
+			return false
+		}
+		agg = &aggregate{name: s.name, tags: s.tags, mtype: s.mtype}
+		series[key] = agg
+	}
+	switch s.mtype {
+	case typeCounter:
+		agg.count += s.value / s.rate
+	case typeGauge:
+		if s.relative {
+			agg.gauge += s.value
+		} else {
+			agg.gauge = s.value
+		}
+		agg.updated = true
+	case typeTimer:
+		agg.count += 1 / s.rate
+		agg.sum += s.value
+		if agg.seen == 0 || s.value < agg.lower {
+			agg.lower = s.value
+		}
+		if agg.seen == 0 || s.value > agg.upper {
+			agg.upper = s.value
+		}
+		agg.seen++
+		// reservoir sampling: each value is kept with probability maxValues/seen
+		if len(agg.values) < a.maxValues {
+			agg.values = append(agg.values, s.value)
+		} else if i := rand.Intn(agg.seen); i < a.maxValues {
+			agg.values[i] = s.value
+		}
+	case typeSet:
+		if agg.set == nil {
+			agg.set = make(map[string]struct{})
+		}
+		if len(agg.set) < a.maxValues {
+			agg.set[s.set] = struct{}{}
+		}
+	}
+	return true
+}
+
+// flush returns the series of the aggregates of the past interval, which
+// lasted the given number of seconds, and starts a new interval.
+// Gauges keep their value for the next interval, so it can be changed by
+// relative values. Like all series, they are only sent when they receive
+// samples, and gauges that didn't are forgotten.
+func (a *aggregator) flush(timestamp int64, interval int) []*schema.MetricData {
+	a.Lock()
+	orgs := a.orgs
+	a.orgs = make(map[int]map[string]*aggregate)
+	for orgId, series := range orgs {
+		for key, agg := range series {
+			if agg.mtype != typeGauge || !agg.updated {
+				continue
+			}
+			if _, ok := a.orgs[orgId]; !ok {
+				a.orgs[orgId] = make(map[string]*aggregate)
+			}
+			a.orgs[orgId][key] = &aggregate{name: agg.name, tags: agg.tags, mtype: agg.mtype, gauge: agg.gauge}
+		}
+	}
+	a.Unlock()
+
+	var buf []*schema.MetricData
+	for orgId, series := range orgs {
+		for _, agg := range series {
+			add := func(name, mtype string, value float64) {
+				md := metricPool.Get()
+				*md = schema.MetricData{
+					Name:     name,
+					Interval: interval,
+					Value:    value,
+					Unit:     "unknown",
+					Time:     timestamp,
+					Mtype:    mtype,
+					Tags:     agg.tags,
+					OrgId:    orgId,
+				}
+				buf = append(buf, md)
+			}
+			switch agg.mtype {
+			case typeCounter:
+				add(agg.name+".count", "count", agg.count)
+				add(agg.name+".rate", "rate", agg.count/float64(interval))
+			case typeGauge:
+				if agg.updated {
+					add(agg.name, "gauge", agg.gauge)
+				}
+			case typeTimer:
+				values := agg.values
+				sort.Float64s(values)
+				add(agg.name+".count", "count", agg.count)
+				add(agg.name+".sum", "gauge", agg.sum)
+				add(agg.name+".lower", "gauge", agg.lower)
+				add(agg.name+".upper", "gauge", agg.upper)
+				add(agg.name+".mean", "gauge", agg.sum/float64(agg.seen))
+				for _, p := range a.percentiles {
+					add(ingest.PercentileName(agg.name, p), "gauge", ingest.Percentile(values, p))
+				}
+			case typeSet:
+				add(agg.name+".count", "gauge", float64(len(agg.set)))
+			}
+		}
+	}
+	return buf
+}
@@ -0,0 +1,137 @@
+package statsd
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/metrictank/schema"
+)
+
+func TestAggregatorFlush(t *testing.T) {
+	a := newAggregator([]float64{50, 99.9}, 100, 100)
+	tags := []string{"app=web"}
+	samples := []struct {
+		orgId  int
+		sample sample
+	}{
+		{1, sample{name: "requests", mtype: typeCounter, value: 1, rate: 1}},
+		{1, sample{name: "requests", mtype: typeCounter, value: 2, rate: 0.5}},
+		{2, sample{name: "requests", mtype: typeCounter, value: 3, rate: 1}},
+		{1, sample{name: "requests", tags: tags, mtype: typeCounter, value: 4, rate: 1}},
+		{1, sample{name: "temp", mtype: typeGauge, value: 10, rate: 1}},
+		{1, sample{name: "temp", mtype: typeGauge, value: -2, rate: 1, relative: true}},
+		{1, sample{name: "latency", mtype: typeTimer, value: 30, rate: 1}},
+		{1, sample{name: "latency", mtype: typeTimer, value: 10, rate: 0.5}},
+		{1, sample{name: "latency", mtype: typeTimer, value: 20, rate: 1}},
+		{1, sample{name: "users", mtype: typeSet, set: "alice", rate: 1}},
+		{1, sample{name: "users", mtype: typeSet, set: "bob", rate: 1}},
+		{1, sample{name: "users", mtype: typeSet, set: "alice", rate: 1}},
+	}
+	for _, s := range samples {
+		a.add(s.orgId, s.sample)
+	}
+
+	type series struct {
+		orgId int
+		name  string
+		tags  string
+		mtype string
+		value float64
+	}
+	summarize := func(buf []*schema.MetricData) []series {
+		var got []series
+		for _, md := range buf {
+			if md.Time != 100 || md.Interval != 10 {
+				t.Errorf("unexpected time or interval of %+v", md)
+			}
+			tags := ""
+			if len(md.Tags) > 0 {
+				tags = md.Tags[0]
+			}
+			got = append(got, series{md.OrgId, md.Name, tags, md.Mtype, md.Value})
+		}
+		sort.Slice(got, func(i, j int) bool {
+			if got[i].orgId != got[j].orgId {
+				return got[i].orgId < got[j].orgId
+			}
+			if got[i].name != got[j].name {
+				return got[i].name < got[j].name
+			}
+			return got[i].tags < got[j].tags
+		})
+		return got
+	}
+
+	want := []series{
+		{1, "latency.count", "", "count", 4},
+		{1, "latency.lower", "", "gauge", 10},
+		{1, "latency.mean", "", "gauge", 20},
+		{1, "latency.p50", "", "gauge", 20},
+		{1, "latency.p99_9", "", "gauge", 30},
+		{1, "latency.sum", "", "gauge", 60},
+		{1, "latency.upper", "", "gauge", 30},
+		{1, "requests.count", "", "count", 5},
+		{1, "requests.count", "app=web", "count", 4},
+		{1, "requests.rate", "", "rate", 0.5},
+		{1, "requests.rate", "app=web", "rate", 0.4},
+		{1, "temp", "", "gauge", 8},
+		{1, "users.count", "", "gauge", 2},
+		{2, "requests.count", "", "count", 3},
+		{2, "requests.rate", "", "rate", 0.3},
+	}
+	if got := summarize(a.flush(100, 10)); !reflect.DeepEqual(got, want) {
+		t.Errorf("flush() = %+v, want %+v", got, want)
+	}
+
+	// the gauge can be changed in the next interval, and is then forgotten
+	a.add(1, sample{name: "temp", mtype: typeGauge, value: 1, rate: 1, relative: true})
+	want = []series{{1, "temp", "", "gauge", 9}}
+	if got := summarize(a.flush(100, 10)); !reflect.DeepEqual(got, want) {
+		t.Errorf("second flush() = %+v, want %+v", got, want)
+	}
+	if got := summarize(a.flush(100, 10)); len(got) != 0 {
+		t.Errorf("third flush() = %+v, want nothing", got)
+	}
+	a.add(1, sample{name: "temp", mtype: typeGauge, value: 1, rate: 1, relative: true})
+	want = []series{{1, "temp", "", "gauge", 1}}
+	if got := summarize(a.flush(100, 10)); !reflect.DeepEqual(got, want) {
+		t.Errorf("fourth flush() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAggregatorLimits(t *testing.T) {
+	a := newAggregator([]float64{50}, 2, 10)
+	if !a.add(1, sample{name: "a", mtype: typeCounter, value: 1, rate: 1}) ||
+		!a.add(1, sample{name: "b", mtype: typeCounter, value: 1, rate: 1}) {
+		t.Fatalf("expected the samples of the first 2 series to be added")
+	}
+	if a.add(1, sample{name: "c", mtype: typeCounter, value: 1, rate: 1}) {
+		t.Errorf("expected a third series of org 1 to be dropped")
+	}
+	if !a.add(1, sample{name: "a", mtype: typeCounter, value: 1, rate: 1}) ||
+		!a.add(2, sample{name: "c", mtype: typeCounter, value: 1, rate: 1}) {
+		t.Errorf("expected samples of existing series and of other orgs to be added")
+	}
+	a.flush(100, 10)
+
+	for i := 1; i <= 100; i++ {
+		a.add(1, sample{name: "latency", mtype: typeTimer, value: float64(i), rate: 1})
+		a.add(1, sample{name: "users", mtype: typeSet, set: strconv.Itoa(i), rate: 1})
+	}
+	agg := a.orgs[1][typeTimer+"|latency;"]
+	if len(agg.values) != 10 || agg.seen != 100 {
+		t.Errorf("expected 10 of the 100 timer values to be kept, got %d of %d", len(agg.values), agg.seen)
+	}
+	got := make(map[string]float64)
+	for _, md := range a.flush(100, 10) {
+		got[md.Name] = md.Value
+	}
+	want := map[string]float64{"latency.count": 100, "latency.sum": 5050, "latency.lower": 1, "latency.upper": 100, "latency.mean": 50.5, "users.count": 10}
+	for name, value := range want {
+		if got[name] != value {
+			t.Errorf("%s = %v, want %v", name, got[name], value)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package statsd
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	errBadFormat = errors.New("expected <name>:<value>|<type>")
+	errBadType   = errors.New("unknown metric type")
+	errBadValue  = errors.New("invalid value")
+	errBadRate   = errors.New("invalid sample rate")
+	errBadTag    = errors.New("tags must be in the format key:value")
+)
+
+// metric types, as sent by clients. Histograms and distributions of
+// DogStatsD are aggregated like timers.
+const (
+	typeCounter = "c"
+	typeGauge   = "g"
+	typeTimer   = "ms"
+	typeSet     = "s"
+)
+
+// sample is a parsed statsd line
+type sample struct {
+	name     string
+	tags     []string // as key=value, sorted
+	mtype    string
+	value    float64
+	set      string  // the member of a set
+	rate     float64 // the sample rate, in (0, 1]
+	relative bool    // whether a gauge value is a change of the gauge
+}
+
+// parseLine parses a statsd line, with the DogStatsD extensions for tags:
+//
+//	<name>:<value>|<type>[|@<sample rate>][|#<tag>:<value>,...]
+//
+// Other DogStatsD fields, like container ids, are ignored.
+func parseLine(buf []byte) (sample, error) {
+	s := sample{rate: 1}
+	parts := strings.Split(string(bytes.TrimSpace(buf)), "|")
+	colon := strings.IndexByte(parts[0], ':')
+	if len(parts) < 2 || colon <= 0 {
+		return s, errBadFormat
+	}
+	s.name = parts[0][:colon]
+	value := parts[0][colon+1:]
+
+	switch parts[1] {
+	case "c", "g", "ms", "s":
+		s.mtype = parts[1]
+	case "h", "d":
+		s.mtype = typeTimer
+	default:
+		return s, errBadType
+	}
+
+	for _, field := range parts[2:] {
+		if field == "" {
+			continue
+		}
+		switch field[0] {
+		case '@':
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || !(rate > 0 && rate <= 1) {
+				return s, errBadRate
+			}
+			s.rate = rate
+		case '#':
+			for _, tag := range strings.Split(field[1:], ",") {
+				pos := strings.IndexByte(tag, ':')
+				if pos <= 0 || pos == len(tag)-1 {
+					return s, errBadTag
+				}
+				s.tags = append(s.tags, tag[:pos]+"="+tag[pos+1:])
+			}
+			sort.Strings(s.tags)
+		}
+	}
+
+	if s.mtype == typeSet {
+		if value == "" {
+			return s, errBadValue
+		}
+		s.set = value
+		return s, nil
+	}
+	if s.mtype == typeGauge && value != "" && (value[0] == '+' || value[0] == '-') {
+		s.relative = true
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return s, errBadValue
+	}
+	s.value = v
+	return s, nil
+}
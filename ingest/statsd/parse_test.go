@@ -0,0 +1,97 @@
+package statsd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		buf     []byte
+		want    sample
+		wantErr error
+	}{
+		{
+			name: "counter",
+			buf:  []byte("key.requests:1|c\n"),
+			want: sample{name: "key.requests", mtype: typeCounter, value: 1, rate: 1},
+		},
+		{
+			name: "sampled counter",
+			buf:  []byte("key.requests:2|c|@0.1"),
+			want: sample{name: "key.requests", mtype: typeCounter, value: 2, rate: 0.1},
+		},
+		{
+			name: "gauge",
+			buf:  []byte("key.temp:-3.5|g"),
+			want: sample{name: "key.temp", mtype: typeGauge, value: -3.5, rate: 1, relative: true},
+		},
+		{
+			name: "absolute gauge",
+			buf:  []byte("key.temp:3.5|g"),
+			want: sample{name: "key.temp", mtype: typeGauge, value: 3.5, rate: 1},
+		},
+		{
+			name: "timer",
+			buf:  []byte("key.latency:320|ms|@0.5"),
+			want: sample{name: "key.latency", mtype: typeTimer, value: 320, rate: 0.5},
+		},
+		{
+			name: "dogstatsd distribution",
+			buf:  []byte("key.latency:320|d"),
+			want: sample{name: "key.latency", mtype: typeTimer, value: 320, rate: 1},
+		},
+		{
+			name: "set",
+			buf:  []byte("key.users:alice|s"),
+			want: sample{name: "key.users", mtype: typeSet, set: "alice", rate: 1},
+		},
+		{
+			name: "dogstatsd tags",
+			buf:  []byte("key.requests:1|c|@1|#region:eu,app:web|c:abcdef"),
+			want: sample{name: "key.requests", mtype: typeCounter, value: 1, rate: 1, tags: []string{"app=web", "region=eu"}},
+		},
+		{
+			name:    "missing type",
+			buf:     []byte("key.requests:1"),
+			wantErr: errBadFormat,
+		},
+		{
+			name:    "missing value",
+			buf:     []byte("key.requests|c"),
+			wantErr: errBadFormat,
+		},
+		{
+			name:    "unknown type",
+			buf:     []byte("key.requests:1|x"),
+			wantErr: errBadType,
+		},
+		{
+			name:    "invalid value",
+			buf:     []byte("key.requests:abc|c"),
+			wantErr: errBadValue,
+		},
+		{
+			name:    "invalid rate",
+			buf:     []byte("key.requests:1|c|@2"),
+			wantErr: errBadRate,
+		},
+		{
+			name:    "tag without value",
+			buf:     []byte("key.requests:1|c|#region"),
+			wantErr: errBadTag,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLine(tt.buf)
+			if err != tt.wantErr {
+				t.Fatalf("parseLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
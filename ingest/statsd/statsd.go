@@ -0,0 +1,225 @@
+package statsd
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/metrictank/schema"
+	"github.com/grafana/metrictank/stats"
+	"github.com/graphite-ng/carbon-relay-ng/input"
+	"github.com/raintank/tsdb-gw/auth"
+	"github.com/raintank/tsdb-gw/ingest"
+	"github.com/raintank/tsdb-gw/publish"
+	"github.com/raintank/tsdb-gw/util"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	metricsReceived          = stats.NewCounterRate32("metrics.statsd.received")
+	metricsRejected          = stats.NewCounterRate32("metrics.statsd.rejected")
+	metricsDroppedAuthFail   = stats.NewCounterRate32("metrics.statsd.dropped_auth_fail")
+	metricsDroppedMaxSeries  = stats.NewCounterRate32("metrics.statsd.dropped_max_series")
+	seriesValid              = stats.NewCounterRate32("metrics.statsd.series.valid")
+	seriesRejected           = stats.NewCounterRate32("metrics.statsd.series.rejected")
+	seriesFailed             = stats.NewCounterRate32("metrics.statsd.series.failed")
+	seriesDroppedRateLimit   = stats.NewCounterRate32("metrics.statsd.series.dropped_rate_limit")
+	seriesDroppedSeriesLimit = stats.NewCounterRate32("metrics.statsd.series.dropped_series_limit")
+
+	Enabled        bool
+	addr           string
+	authPlugin     string
+	apiKey         string
+	flushInterval  time.Duration
+	percentilesStr string
+	maxSeries      int
+	maxValues      int
+
+	metricPool = util.NewMetricDataPool()
+)
+
+func init() {
+	flag.BoolVar(&Enabled, "statsd-enabled", false, "enable statsd input")
+	flag.StringVar(&addr, "statsd-addr", "0.0.0.0:8125", "listen address for statsd input, over both UDP and TCP")
+	flag.StringVar(&authPlugin, "statsd-auth-plugin", "file", "auth plugin to use. (grafana|file)")
+	flag.StringVar(&apiKey, "statsd-api-key", "", "api key of the org to store all statsd metrics in. When empty, every metric name must be prefixed with an api key, like with carbon")
+	flag.DurationVar(&flushInterval, "statsd-flush-interval", 10*time.Second, "interval at which statsd metrics are aggregated and published. Also the interval of the resulting series")
+	flag.StringVar(&percentilesStr, "statsd-percentiles", "90", "comma separated percentiles of statsd timers to store, each as a <metric>.p<percentile> series")
+	flag.IntVar(&maxSeries, "statsd-max-series", 100000, "maximum number of statsd series aggregated per org in a flush interval. Lines of further series are dropped")
+	flag.IntVar(&maxValues, "statsd-max-values", 10000, "maximum number of values kept per statsd timer and set in a flush interval. Timer percentiles are computed from a random sample of this many values, sets stop counting new members")
+}
+
+type Statsd struct {
+	listener         *input.Listener
+	agg              *aggregator
+	authPlugin       auth.AuthPlugin
+	apiKey           string
+	requirePublisher bool
+	shutdown         chan struct{}
+	flushWg          sync.WaitGroup
+
+	// Dispatch holds a read lock, so that Stop can wait for the lines being
+	// aggregated before the final flush
+	dispatchLock sync.RWMutex
+	stopped      bool
+}
+
+func InitStatsd(requirePublisher bool) *Statsd {
+	if !Enabled {
+		return &Statsd{}
+	}
+	if flushInterval < time.Second || flushInterval%time.Second != 0 {
+		log.Fatalf("statsd-flush-interval must be a whole number of seconds, got %s", flushInterval)
+	}
+	percentiles, err := ingest.ParsePercentiles(percentilesStr)
+	if err != nil {
+		log.Fatalf("invalid statsd-percentiles. %s", err)
+	}
+	if maxSeries < 1 || maxValues < 1 {
+		log.Fatalf("statsd-max-series and statsd-max-values must be at least 1")
+	}
+
+	s := &Statsd{
+		agg:              newAggregator(percentiles, maxSeries, maxValues),
+		authPlugin:       auth.GetAuthPlugin(authPlugin),
+		apiKey:           apiKey,
+		requirePublisher: requirePublisher,
+		shutdown:         make(chan struct{}),
+	}
+	// the plain handler dispatches each line of tcp connections and udp
+	// datagrams to us
+	s.listener = input.NewListener(addr, 2*time.Minute, input.NewPlain(s))
+	if err := s.listener.Start(); err != nil {
+		log.Fatal(err)
+	}
+	s.flushWg.Add(1)
+	go s.flush()
+	return s
+}
+
+// Stop stops the listener and publishes what was aggregated so far
+func (s *Statsd) Stop() {
+	if !Enabled {
+		return
+	}
+	s.listener.Stop()
+	// wait for the lines being dispatched, lines dispatched later are dropped
+	s.dispatchLock.Lock()
+	s.stopped = true
+	s.dispatchLock.Unlock()
+	close(s.shutdown)
+	s.flushWg.Wait()
+}
+
+// IncNumInvalid is never called by the plain handler
+func (s *Statsd) IncNumInvalid() {
+	metricsRejected.Inc()
+}
+
+// Dispatch parses a statsd line and adds it to the aggregates of its org
+func (s *Statsd) Dispatch(buf []byte) {
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 {
+		return
+	}
+	s.dispatchLock.RLock()
+	defer s.dispatchLock.RUnlock()
+	if s.stopped {
+		return
+	}
+	metricsReceived.Inc()
+	sample, err := parseLine(buf)
+	if err != nil {
+		log.Debugf("statsd line rejected with error. %s - %s", err, buf)
+		metricsRejected.Inc()
+		return
+	}
+
+	key := s.apiKey
+	if key == "" {
+		parts := strings.SplitN(sample.name, ".", 2)
+		if len(parts) != 2 {
+			log.Debugf("statsd line rejected, no auth key prefix. %s", buf)
+			metricsDroppedAuthFail.Inc()
+			return
+		}
+		key, sample.name = parts[0], parts[1]
+	}
+	user, err := auth.AuthPublisher(s.authPlugin, "api_key", key, s.requirePublisher)
+	if err != nil {
+		log.Debugf("invalid statsd auth key. %s, reason: %v", key, err)
+		metricsDroppedAuthFail.Inc()
+		return
+	}
+	if !s.agg.add(user.ID, sample) {
+		log.Debugf("statsd line of org %d dropped, over statsd-max-series. %s", user.ID, buf)
+		metricsDroppedMaxSeries.Inc()
+	}
+}
+
+func (s *Statsd) flush() {
+	defer s.flushWg.Done()
+	interval := int(flushInterval / time.Second)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.publish(s.agg.flush(now.Unix(), interval))
+		case <-s.shutdown:
+			s.publish(s.agg.flush(time.Now().Unix(), interval))
+			return
+		}
+	}
+}
+
+// publish validates the aggregated series and publishes the valid ones.
+// Like carbon, series over the rate limit of their org are dropped, and only
+// the remaining ones are subject to the series limits.
+func (s *Statsd) publish(buf []*schema.MetricData) {
+	defer func() {
+		for _, md := range buf {
+			metricPool.Put(md)
+		}
+	}()
+	kept, dropped := ingest.FilterSeriesLimit(filterSeries(buf))
+	seriesDroppedSeriesLimit.Add(dropped)
+	if len(kept) == 0 {
+		return
+	}
+	ingest.ClampTimestamps(kept)
+	if err := publish.Publish(kept); err != nil {
+		log.Errorf("failed to publish statsd metrics. %s", err)
+		seriesFailed.Add(len(kept))
+		return
+	}
+	seriesValid.Add(len(kept))
+}
+
+// filterSeries returns the series that are valid and within the rate limits
+// of their orgs, and sets their ids.
+func filterSeries(buf []*schema.MetricData) []*schema.MetricData {
+	kept := make([]*schema.MetricData, 0, len(buf))
+	for _, md := range buf {
+		err := md.Validate()
+		if err == nil {
+			err = ingest.ValidateMetric(md)
+		}
+		if err != nil {
+			log.Debugf("statsd series of org %d rejected: %s. %s", md.OrgId, err, md.Name)
+			seriesRejected.Inc()
+			ingest.CountDiscarded(md.OrgId, err.Error(), 1)
+			continue
+		}
+		md.SetId()
+		if !ingest.AllowDatapoints(md.OrgId, 1) {
+			log.Debugf("statsd series of org %d dropped due to rate limit. %s", md.OrgId, md.Name)
+			seriesDroppedRateLimit.Inc()
+			continue
+		}
+		kept = append(kept, md)
+	}
+	return kept
+}
@@ -0,0 +1,233 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/metrictank/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ErrNameTooLong      = errors.New("metric name too long")
+	ErrTooManyTags      = errors.New("too many tags")
+	ErrTagKeyTooLong    = errors.New("tag key too long")
+	ErrTagValueTooLong  = errors.New("tag value too long")
+	ErrInvalidCharacter = errors.New("invalid character in metric name or tag key")
+	ErrReservedTag      = errors.New("reserved tag")
+	ErrTimestampTooOld  = errors.New("timestamp too old")
+	ErrTimestampTooNew  = errors.New("timestamp too far in the future")
+
+	validation validationRules
+
+	clampedSamples = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "gateway",
+			Name:      "clamped_samples_total",
+			Help:      "The total number of samples whose out of range timestamp was replaced by the nearest allowed timestamp.",
+		},
+		[]string{"reason", "org"},
+	)
+)
+
+// ValidationConfig defines the checks applied to the metrics of all ingest
+// protocols, on top of what the protocol itself requires. Zero values disable
+// the check.
+type ValidationConfig struct {
+	MaxNameLength     int
+	MaxTags           int
+	MaxTagKeyLength   int
+	MaxTagValueLength int
+	AllowedChars      string   // characters allowed in names and tag keys, as the contents of a regexp character class, e.g. "a-zA-Z0-9_.-"
+	ReservedTags      []string // tag keys that clients may not set
+	MaxAge            time.Duration
+	MaxFutureSkew     time.Duration
+	ClampTimestamps   bool // set out of range timestamps to the nearest allowed one, instead of rejecting the metric
+}
+
+type validationRules struct {
+	ValidationConfig
+	allowedChars *regexp.Regexp
+	reservedTags map[string]struct{}
+}
+
+// ConfigureValidation sets the checks applied by ValidateMetric
+func ConfigureValidation(config ValidationConfig) error {
+	rules := validationRules{
+		ValidationConfig: config,
+	}
+	if config.AllowedChars != "" {
+		re, err := regexp.Compile("^[" + config.AllowedChars + "]*$")
+		if err != nil {
+			return fmt.Errorf("invalid set of allowed characters %q: %s", config.AllowedChars, err)
+		}
+		rules.allowedChars = re
+	}
+	for _, tag := range config.ReservedTags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if rules.reservedTags == nil {
+			rules.reservedTags = make(map[string]struct{})
+		}
+		rules.reservedTags[tag] = struct{}{}
+	}
+	validation = rules
+	return nil
+}
+
+// ValidateMetric applies the configured checks to the metric. With
+// ClampTimestamps, metrics with an out of range timestamp pass, and their
+// timestamp is clamped by ClampTimestamp once they are accepted.
+func ValidateMetric(md *schema.MetricData) error {
+	return validateMetric(md, time.Now())
+}
+
+func validateMetric(md *schema.MetricData, now time.Time) error {
+	if validation.MaxNameLength > 0 && len(md.Name) > validation.MaxNameLength {
+		return ErrNameTooLong
+	}
+	if validation.allowedChars != nil && !validation.allowedChars.MatchString(md.Name) {
+		return ErrInvalidCharacter
+	}
+	if validation.MaxTags > 0 && len(md.Tags) > validation.MaxTags {
+		return ErrTooManyTags
+	}
+	for _, tag := range md.Tags {
+		key, value := tag, ""
+		if pos := strings.IndexByte(tag, '='); pos >= 0 {
+			key, value = tag[:pos], tag[pos+1:]
+		}
+		if validation.MaxTagKeyLength > 0 && len(key) > validation.MaxTagKeyLength {
+			return ErrTagKeyTooLong
+		}
+		if validation.MaxTagValueLength > 0 && len(value) > validation.MaxTagValueLength {
+			return ErrTagValueTooLong
+		}
+		if validation.allowedChars != nil && !validation.allowedChars.MatchString(key) {
+			return ErrInvalidCharacter
+		}
+		if _, ok := validation.reservedTags[key]; ok {
+			return ErrReservedTag
+		}
+	}
+	if _, err := timestampBound(md, now); err != nil && !validation.ClampTimestamps {
+		return err
+	}
+	return nil
+}
+
+// ClampTimestamp sets the timestamp of an accepted metric to the nearest
+// allowed timestamp if it is out of range and ClampTimestamps is enabled, and
+// counts the metric as clamped.
+func ClampTimestamp(md *schema.MetricData) {
+	clampTimestamp(md, time.Now())
+}
+
+// ClampTimestamps applies ClampTimestamp to the metrics of an accepted request
+func ClampTimestamps(mds []*schema.MetricData) {
+	if !validation.ClampTimestamps {
+		return
+	}
+	now := time.Now()
+	for _, md := range mds {
+		clampTimestamp(md, now)
+	}
+}
+
+func clampTimestamp(md *schema.MetricData, now time.Time) {
+	if !validation.ClampTimestamps {
+		return
+	}
+	bound, err := timestampBound(md, now)
+	if err == nil {
+		return
+	}
+	clampedSamples.WithLabelValues(err.Error(), strconv.Itoa(md.OrgId)).Inc()
+	md.Time = bound
+}
+
+// timestampBound returns the reason why the timestamp of the metric is out of
+// range, and the nearest allowed timestamp
+func timestampBound(md *schema.MetricData, now time.Time) (int64, error) {
+	var bound int64
+	var err error
+	if validation.MaxAge > 0 {
+		if oldest := now.Add(-validation.MaxAge).Unix(); md.Time < oldest {
+			bound, err = oldest, ErrTimestampTooOld
+		}
+	}
+	if validation.MaxFutureSkew > 0 {
+		if newest := now.Add(validation.MaxFutureSkew).Unix(); md.Time > newest {
+			bound, err = newest, ErrTimestampTooNew
+		}
+	}
+	return bound, err
+}
+
+// FilterMetrics validates the metrics, whose interval may be 0, sets their ids
+// and adds them to batch. It returns the metrics that pass, and records the
+// others in resp and in the discarded samples counter. buf is not modified.
+func FilterMetrics(buf []*schema.MetricData, resp *MetricsResponse, batch *Batch) []*schema.MetricData {
+	var kept []*schema.MetricData
+	discards := make(discardsByOrg)
+	for i, md := range buf {
+		if err := prepareMetric(md, batch); err != nil {
+			if kept == nil {
+				kept = make([]*schema.MetricData, i, len(buf))
+				copy(kept, buf[:i])
+			}
+			resp.AddInvalid(err, i)
+			discards.Add(md.OrgId, err.Error())
+			continue
+		}
+		if kept != nil {
+			kept = append(kept, md)
+		}
+	}
+	if kept == nil {
+		return buf
+	}
+	metricsRejected.Add(len(buf) - len(kept))
+	discards.Track()
+	return kept
+}
+
+// prepareMetric validates a metric whose interval may be 0, sets its id and
+// adds it to batch
+func prepareMetric(md *schema.MetricData, batch *Batch) error {
+	if err := validateAndSetId(md); err != nil {
+		return err
+	}
+	return batch.Add(md)
+}
+
+// validateAndSetId validates a metric whose interval may be 0 and sets its id
+func validateAndSetId(md *schema.MetricData) error {
+	err := validateUnknownInterval(md)
+	if err == nil {
+		err = ValidateMetric(md)
+	}
+	if err == nil {
+		md.SetId()
+	}
+	return err
+}
+
+// validateUnknownInterval validates a metric whose interval may be 0, meaning
+// that it's deduced from the storage schemas when it's published.
+func validateUnknownInterval(md *schema.MetricData) error {
+	if md.Interval != 0 {
+		return md.Validate()
+	}
+	md.Interval = 1
+	err := md.Validate()
+	md.Interval = 0
+	return err
+}
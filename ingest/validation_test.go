@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/metrictank/schema"
+)
+
+func TestValidateMetric(t *testing.T) {
+	err := ConfigureValidation(ValidationConfig{
+		MaxNameLength:     10,
+		MaxTags:           2,
+		MaxTagKeyLength:   5,
+		MaxTagValueLength: 8,
+		AllowedChars:      "a-z0-9_.",
+		ReservedTags:      []string{"__name__", "org"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureValidation(ValidationConfig{})
+
+	tests := []struct {
+		name     string
+		metric   schema.MetricData
+		expected error
+	}{
+		{"valid", schema.MetricData{Name: "a.b_c", Tags: []string{"env=prod", "dc=a b/c"}}, nil},
+		{"name_at_limit", schema.MetricData{Name: strings.Repeat("a", 10)}, nil},
+		{"name_too_long", schema.MetricData{Name: strings.Repeat("a", 11)}, ErrNameTooLong},
+		{"too_many_tags", schema.MetricData{Name: "a", Tags: []string{"a=1", "b=2", "c=3"}}, ErrTooManyTags},
+		{"tag_key_too_long", schema.MetricData{Name: "a", Tags: []string{"region=eu"}}, ErrTagKeyTooLong},
+		{"tag_value_too_long", schema.MetricData{Name: "a", Tags: []string{"dc=eu-west-1a"}}, ErrTagValueTooLong},
+		{"invalid_name_char", schema.MetricData{Name: "a-b"}, ErrInvalidCharacter},
+		{"invalid_tag_key_char", schema.MetricData{Name: "a", Tags: []string{"Env=prod"}}, ErrInvalidCharacter},
+		{"reserved_tag", schema.MetricData{Name: "a", Tags: []string{"org=1"}}, ErrReservedTag},
+	}
+	for _, tt := range tests {
+		if err := ValidateMetric(&tt.metric); err != tt.expected {
+			t.Errorf("%s: ValidateMetric() = %v, expected %v", tt.name, err, tt.expected)
+		}
+	}
+
+	if err := ConfigureValidation(ValidationConfig{AllowedChars: "z-a"}); err == nil {
+		t.Fatalf("expected invalid character set to be rejected")
+	}
+}
+
+func TestFilterMetrics(t *testing.T) {
+	InitSeriesLimits(time.Hour)
+	if err := ConfigureSeriesLimits("1:1", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureSeriesLimits("", "")
+	if err := ConfigureValidation(ValidationConfig{MaxNameLength: 5}); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureValidation(ValidationConfig{})
+
+	buf := []*schema.MetricData{newSeries(2, "a"), newSeries(2, "b")}
+	resp := NewMetricsResponse()
+	kept := FilterMetrics(buf, &resp, NewBatch())
+	if resp.Invalid != 0 || len(kept) != 2 {
+		t.Fatalf("expected all metrics to be kept, got %d kept and %d invalid", len(kept), resp.Invalid)
+	}
+
+	buf = []*schema.MetricData{newSeries(1, "a"), newSeries(1, "b"), newSeries(2, "c"), newSeries(2, "toolong"), newSeries(1, "a")}
+	resp = NewMetricsResponse()
+	kept = FilterMetrics(buf, &resp, NewBatch())
+	if len(kept) != 3 || kept[1] != buf[2] || kept[2] != buf[4] {
+		t.Fatalf("expected the metrics of series b and toolong to be removed, got %d kept", len(kept))
+	}
+	if resp.Invalid != 2 || resp.ValidationErrors[ErrSeriesLimitExceeded.Error()].ExampleIds[0] != 1 || resp.ValidationErrors[ErrNameTooLong.Error()].ExampleIds[0] != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if buf[1].Name != "b" {
+		t.Fatalf("expected buf not to be modified")
+	}
+
+	invalid := newSeries(2, "d")
+	invalid.Mtype = ""
+	resp = NewMetricsResponse()
+	kept = FilterMetrics([]*schema.MetricData{newSeries(2, "a"), invalid}, &resp, NewBatch())
+	if len(kept) != 1 || resp.ValidationErrors[schema.ErrInvalidMtype.Error()].Count != 1 {
+		t.Fatalf("expected the metric without type to be rejected, got %+v", resp)
+	}
+}
+
+func TestValidateTimestamp(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	tests := []struct {
+		name     string
+		clamp    bool
+		ts       int64
+		expected error
+		expTs    int64
+	}{
+		{"in_range", false, 1000000, nil, 1000000},
+		{"oldest_allowed", false, 1000000 - 3600, nil, 1000000 - 3600},
+		{"too_old", false, 1000000 - 3601, ErrTimestampTooOld, 1000000 - 3601},
+		{"epoch", false, 0, ErrTimestampTooOld, 0},
+		{"newest_allowed", false, 1000000 + 60, nil, 1000000 + 60},
+		{"too_new", false, 1000000 + 61, ErrTimestampTooNew, 1000000 + 61},
+		{"clamp_too_old", true, 0, nil, 1000000 - 3600},
+		{"clamp_too_new", true, 2000000, nil, 1000000 + 60},
+	}
+	defer ConfigureValidation(ValidationConfig{})
+	for _, tt := range tests {
+		ConfigureValidation(ValidationConfig{
+			MaxAge:          time.Hour,
+			MaxFutureSkew:   time.Minute,
+			ClampTimestamps: tt.clamp,
+		})
+		md := schema.MetricData{Name: "a", OrgId: 1, Time: tt.ts}
+		if err := validateMetric(&md, now); err != tt.expected {
+			t.Errorf("%s: validateMetric() = %v, expected %v", tt.name, err, tt.expected)
+		}
+		if md.Time != tt.ts {
+			t.Errorf("%s: expected validateMetric not to change the timestamp, got %d", tt.name, md.Time)
+		}
+		clampTimestamp(&md, now)
+		if md.Time != tt.expTs {
+			t.Errorf("%s: expected timestamp %d, got %d", tt.name, tt.expTs, md.Time)
+		}
+	}
+}
+
+func TestClampTimestamps(t *testing.T) {
+	buf := []*schema.MetricData{
+		{Name: "old", OrgId: 1, Time: 1},
+		{Name: "now", OrgId: 1, Time: time.Now().Unix()},
+	}
+	ClampTimestamps(buf)
+	if buf[0].Time != 1 {
+		t.Fatalf("expected the timestamp not to be clamped by default, got %d", buf[0].Time)
+	}
+
+	if err := ConfigureValidation(ValidationConfig{MaxAge: time.Hour, ClampTimestamps: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer ConfigureValidation(ValidationConfig{})
+	now := buf[1].Time
+	ClampTimestamps(buf)
+	if buf[0].Time < time.Now().Add(-time.Hour).Unix() {
+		t.Fatalf("expected the timestamp to be clamped, got %d", buf[0].Time)
+	}
+	if buf[1].Time != now {
+		t.Fatalf("expected the timestamp in range to be kept, got %d", buf[1].Time)
+	}
+}
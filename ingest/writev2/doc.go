@@ -0,0 +1,8 @@
+// Package writev2 holds the protobuf types of prometheus' remote write 2.0,
+// generated from io/prometheus/write/v2/types.proto.
+//
+// types.pb.go is copied from prompb/io/prometheus/write/v2 of prometheus
+// v0.315.0 (Apache License 2.0, see vendor/github.com/prometheus/prometheus/LICENSE).
+// The upstream package can't be vendored: its helpers import the labels
+// package of prometheus, which needs the /v2 import path of xxhash.
+package writev2
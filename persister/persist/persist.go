@@ -3,10 +3,14 @@ package persist
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
-	"github.com/sirupsen/logrus"
+	"github.com/raintank/tsdb-gw/util"
 )
 
 // The persist package contains client to push metrics to a persister service
@@ -25,33 +29,47 @@ type Client struct {
 	client *http.Client
 }
 
+// NewClient returns a client of the persister-gw at addr
 func NewClient(addr string) (*Client, error) {
 	u, err := url.Parse(addr)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse gw.addr: '%v'", addr)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("unable to parse persister address: '%v'", addr)
 	}
+	u.Path = util.JoinUrlFragments(u.Path, "/persist")
 
 	return &Client{
 		url:    u.String(),
-		client: &http.Client{},
+		client: &http.Client{Timeout: 10 * time.Second},
 	}, nil
 }
 
 func (c *Client) PushIntake(payload []byte) error {
-	c.client.Post(c.url, "application/json", bytes.NewBuffer(payload))
+	resp, err := c.client.Post(c.url, "application/json", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("persister responded with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
 	return nil
 }
-func Init(addr string) {
-	cli, err := NewClient(addr)
 
+// Init makes Persist send the payloads to the persister-gw at addr
+func Init(addr string) error {
+	cli, err := NewClient(addr)
 	if err != nil {
-		logrus.Fatalf("unable to initialize peristor: %v", err)
+		return err
 	}
 
 	client = cli
 	enabled = true
+	return nil
 }
 
+// Persist sends the payload to the persister-gw, if one is configured
 func Persist(data []byte) error {
 	if enabled {
 		return client.PushIntake(data)
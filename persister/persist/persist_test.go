@@ -0,0 +1,40 @@
+package persist
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPushIntake(t *testing.T) {
+	var path, body string
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL + "/gw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.PushIntake([]byte(`{"orgID":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/gw/persist" || body != `{"orgID":1}` {
+		t.Fatalf("unexpected request to %s: %s", path, body)
+	}
+
+	status = http.StatusInternalServerError
+	if err := c.PushIntake([]byte(`{"orgID":1}`)); err == nil {
+		t.Fatalf("expected an error when the persister fails")
+	}
+
+	if _, err := NewClient("localhost:8081"); err == nil {
+		t.Fatalf("expected an address without scheme to be rejected")
+	}
+}
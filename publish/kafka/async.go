@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/grafana/metrictank/stats"
+	"github.com/raintank/tsdb-gw/publish"
+)
+
+var (
+	asyncSends    = stats.NewGauge32("output.kafka.async.concurrent_sends")
+	asyncRejected = stats.NewCounterRate32("output.kafka.async.rejected")
+)
+
+// asyncProducer publishes via a sarama.AsyncProducer, while still implementing
+// sarama.SyncProducer: callers block until all of their messages have been
+// acked or have failed, so they learn about the outcome of their batch.
+// The messages of concurrent calls are batched together by the AsyncProducer.
+// The number of concurrent SendMessages calls is bounded, and so are the
+// messages waiting for their acks. Once the limit is reached, SendMessages
+// fails with publish.ErrBackpressure rather than queueing up more.
+type asyncProducer struct {
+	producer       sarama.AsyncProducer
+	sends          chan struct{} // a slot for each concurrent SendMessages call
+	enqueueTimeout time.Duration
+	wg             sync.WaitGroup
+}
+
+// asyncBatch tracks the completion of the messages of a single SendMessages call
+type asyncBatch struct {
+	remaining int32
+	done      chan struct{}
+
+	sync.Mutex
+	errs sarama.ProducerErrors
+}
+
+func newAsyncProducer(producer sarama.AsyncProducer, maxSends int, enqueueTimeout time.Duration) *asyncProducer {
+	p := &asyncProducer{
+		producer:       producer,
+		sends:          make(chan struct{}, maxSends),
+		enqueueTimeout: enqueueTimeout,
+	}
+	p.wg.Add(2)
+	go p.handleSuccesses()
+	go p.handleErrors()
+	return p
+}
+
+func (p *asyncProducer) handleSuccesses() {
+	defer p.wg.Done()
+	for msg := range p.producer.Successes() {
+		p.complete(msg, nil)
+	}
+}
+
+func (p *asyncProducer) handleErrors() {
+	defer p.wg.Done()
+	for err := range p.producer.Errors() {
+		p.complete(err.Msg, err)
+	}
+}
+
+func (p *asyncProducer) complete(msg *sarama.ProducerMessage, err *sarama.ProducerError) {
+	b := msg.Metadata.(*asyncBatch)
+	if err != nil {
+		b.Lock()
+		b.errs = append(b.errs, err)
+		b.Unlock()
+	}
+	if atomic.AddInt32(&b.remaining, -1) == 0 {
+		close(b.done)
+	}
+}
+
+// acquire reserves a slot for a SendMessages call, waiting at most enqueueTimeout
+func (p *asyncProducer) acquire() bool {
+	select {
+	case p.sends <- struct{}{}:
+		return true
+	default:
+	}
+	if p.enqueueTimeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(p.enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case p.sends <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (p *asyncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if !p.acquire() {
+		asyncRejected.Inc()
+		return publish.ErrBackpressure
+	}
+	asyncSends.Inc()
+	defer func() {
+		<-p.sends
+		asyncSends.Dec()
+	}()
+
+	b := &asyncBatch{
+		remaining: int32(len(msgs)),
+		done:      make(chan struct{}),
+	}
+	for _, msg := range msgs {
+		msg.Metadata = b
+		p.producer.Input() <- msg
+	}
+	<-b.done
+
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	return nil
+}
+
+func (p *asyncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	err := p.SendMessages([]*sarama.ProducerMessage{msg})
+	return msg.Partition, msg.Offset, err
+}
+
+func (p *asyncProducer) Close() error {
+	p.producer.AsyncClose()
+	p.wg.Wait()
+	return nil
+}
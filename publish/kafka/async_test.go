@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/raintank/tsdb-gw/publish"
+)
+
+func asyncTestMessages(n int) []*sarama.ProducerMessage {
+	msgs := make([]*sarama.ProducerMessage, n)
+	for i := range msgs {
+		msgs[i] = &sarama.ProducerMessage{
+			Topic: "testTopic",
+			Value: sarama.ByteEncoder("value"),
+		}
+	}
+	return msgs
+}
+
+func TestAsyncProducerSendMessages(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	mockProducer := mocks.NewAsyncProducer(t, config)
+	p := newAsyncProducer(mockProducer, 2, 0)
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		mockProducer.ExpectInputAndSucceed()
+	}
+	if err := p.SendMessages(asyncTestMessages(3)); err != nil {
+		t.Fatalf("SendMessages() returned error: %s", err)
+	}
+
+	errFailed := errors.New("failed")
+	mockProducer.ExpectInputAndSucceed()
+	mockProducer.ExpectInputAndFail(errFailed)
+	err := p.SendMessages(asyncTestMessages(2))
+	prodErrs, ok := err.(sarama.ProducerErrors)
+	if !ok || len(prodErrs) != 1 || prodErrs[0].Err != errFailed {
+		t.Fatalf("expected a single producer error, got %v", err)
+	}
+	if len(p.sends) != 0 {
+		t.Fatalf("expected no concurrent sends, has %d", len(p.sends))
+	}
+}
+
+func TestAsyncProducerBackpressure(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	mockProducer := mocks.NewAsyncProducer(t, config)
+	p := newAsyncProducer(mockProducer, 1, 0)
+	defer p.Close()
+
+	// occupy the only slot, as if another call was waiting for its acks
+	p.sends <- struct{}{}
+	if err := p.SendMessages(asyncTestMessages(1)); err != publish.ErrBackpressure {
+		t.Fatalf("expected ErrBackpressure, got %v", err)
+	}
+	<-p.sends
+
+	mockProducer.ExpectInputAndSucceed()
+	if err := p.SendMessages(asyncTestMessages(1)); err != nil {
+		t.Fatalf("SendMessages() returned error: %s", err)
+	}
+}
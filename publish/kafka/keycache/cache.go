@@ -1,6 +1,10 @@
 package keycache
 
-import schema "github.com/grafana/metrictank/schema"
+import (
+	"sync/atomic"
+
+	schema "github.com/grafana/metrictank/schema"
+)
 
 // Cache is a single-tenant keycache
 // it is sharded for 2 reasons:
@@ -10,6 +14,7 @@ import schema "github.com/grafana/metrictank/schema"
 // is evenly distributed.
 type Cache struct {
 	shards [256]Shard
+	count  int64 // number of keys across all shards
 }
 
 // NewCache creates a new cache
@@ -23,8 +28,28 @@ func NewCache() *Cache {
 
 // Touch marks the key as seen and returns whether it was seen before
 func (c *Cache) Touch(key schema.Key) bool {
+	seen, _ := c.TouchLimited(key, 0)
+	return seen
+}
+
+// TouchLimited marks the key as seen, unless it's new and the cache already
+// holds limit keys. A limit of 0 means no limit.
+// It returns whether the key was seen before and whether it is seen now.
+func (c *Cache) TouchLimited(key schema.Key, limit int) (bool, bool) {
+	shard := int(key[0])
+	return c.shards[shard].TouchLimited(key, &c.count, limit)
+}
+
+// Seen returns whether the key was seen, without marking it as seen
+func (c *Cache) Seen(key schema.Key) bool {
 	shard := int(key[0])
-	return c.shards[shard].Touch(key)
+	return c.shards[shard].Seen(key)
+}
+
+// Count returns the number of keys in the cache. Unlike Len it is cheap,
+// but it may be slightly off while keys are being added or cleared
+func (c *Cache) Count() int {
+	return int(atomic.LoadInt64(&c.count))
 }
 
 // Len returns the length of the cache
@@ -38,6 +63,7 @@ func (c *Cache) Len() int {
 
 // Clear resets the given shard
 func (c *Cache) Clear(i int) int {
-	c.shards[i].Reset()
+	removed := c.shards[i].Reset()
+	atomic.AddInt64(&c.count, -int64(removed))
 	return c.Len()
 }
@@ -31,6 +31,14 @@ func NewKeyCache(clearInterval time.Duration) *KeyCache {
 // Touch marks the key as seen and returns whether it was seen before
 // callers should assure that t >= ref and t-ref <= 42 hours
 func (k *KeyCache) Touch(key schema.MKey) bool {
+	seen, _ := k.TouchLimited(key, 0)
+	return seen
+}
+
+// TouchLimited marks the key as seen, unless it's new and its org already has
+// limit keys. A limit of 0 means no limit.
+// It returns whether the key was seen before and whether it is seen now.
+func (k *KeyCache) TouchLimited(key schema.MKey, limit int) (bool, bool) {
 	k.RLock()
 	cache, ok := k.caches[key.Org]
 	k.RUnlock()
@@ -45,7 +53,37 @@ func (k *KeyCache) Touch(key schema.MKey) bool {
 		}
 		k.Unlock()
 	}
-	return cache.Touch(key.Key)
+	return cache.TouchLimited(key.Key, limit)
+}
+
+// Seen returns whether the key was seen, without marking it as seen
+func (k *KeyCache) Seen(key schema.MKey) bool {
+	k.RLock()
+	cache, ok := k.caches[key.Org]
+	k.RUnlock()
+	return ok && cache.Seen(key.Key)
+}
+
+// Count returns the number of keys of the org
+func (k *KeyCache) Count(org uint32) int {
+	k.RLock()
+	cache, ok := k.caches[org]
+	k.RUnlock()
+	if !ok {
+		return 0
+	}
+	return cache.Count()
+}
+
+// OrgCounts returns the number of keys of each org
+func (k *KeyCache) OrgCounts() map[uint32]int {
+	k.RLock()
+	defer k.RUnlock()
+	counts := make(map[uint32]int, len(k.caches))
+	for org, c := range k.caches {
+		counts[org] = c.Count()
+	}
+	return counts
 }
 
 // Len returns the size across all orgs
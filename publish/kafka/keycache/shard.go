@@ -2,6 +2,7 @@ package keycache
 
 import (
 	"sync"
+	"sync/atomic"
 
 	schema "github.com/grafana/metrictank/schema"
 )
@@ -35,6 +36,35 @@ func (s *Shard) Touch(key schema.Key) bool {
 	return ok
 }
 
+// TouchLimited is like Touch, but doesn't add a key that wasn't seen before
+// when count has reached limit. When it adds the key, it increments count.
+// It returns whether the key was seen before and whether it is seen now.
+func (s *Shard) TouchLimited(key schema.Key, count *int64, limit int) (bool, bool) {
+	var sub SubKey
+	copy(sub[:], key[1:])
+	s.Lock()
+	defer s.Unlock()
+	if _, ok := s.data[sub]; ok {
+		return true, true
+	}
+	if limit > 0 && atomic.LoadInt64(count) >= int64(limit) {
+		return false, false
+	}
+	s.data[sub] = struct{}{}
+	atomic.AddInt64(count, 1)
+	return false, true
+}
+
+// Seen returns whether the key was seen, without marking it as seen
+func (s *Shard) Seen(key schema.Key) bool {
+	var sub SubKey
+	copy(sub[:], key[1:])
+	s.Lock()
+	_, ok := s.data[sub]
+	s.Unlock()
+	return ok
+}
+
 // Len returns the length of the shard
 func (s *Shard) Len() int {
 	s.Lock()
@@ -43,9 +73,11 @@ func (s *Shard) Len() int {
 	return l
 }
 
-// Reset resets the shard, making it empty
-func (s *Shard) Reset() {
+// Reset resets the shard, making it empty. It returns the number of keys removed
+func (s *Shard) Reset() int {
 	s.Lock()
+	l := len(s.data)
 	s.data = make(map[SubKey]struct{})
 	s.Unlock()
+	return l
 }
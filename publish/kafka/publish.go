@@ -3,8 +3,11 @@ package kafka
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/metrictank/conf"
@@ -15,8 +18,10 @@ import (
 	"github.com/grafana/metrictank/schema"
 	"github.com/grafana/metrictank/schema/msg"
 	"github.com/grafana/metrictank/stats"
+	"github.com/raintank/tsdb-gw/publish"
 	"github.com/raintank/tsdb-gw/publish/kafka/keycache"
 	"github.com/raintank/tsdb-gw/util"
+	"github.com/rcrowley/go-metrics"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -24,6 +29,7 @@ var (
 	producer        sarama.SyncProducer
 	kafkaVersionStr string
 	keyCache        *keycache.KeyCache
+	pubSpool        *spool
 
 	schemasConf string
 
@@ -34,6 +40,8 @@ var (
 	publishDuration = stats.NewLatencyHistogram15s32("metrics.publish")
 	sendErrProducer = stats.NewCounterRate32("metrics.send_error.producer")
 	sendErrOther    = stats.NewCounterRate32("metrics.send_error.other")
+	// compression ratio of produced record batches, times 100
+	compressionRatio = stats.NewGauge32("output.kafka.compression_ratio")
 
 	topicsStr           string
 	rewriteOrgIdStr     string
@@ -51,6 +59,14 @@ var (
 	v2Org               bool
 	v2ClearInterval     time.Duration
 	flushFreq           time.Duration
+	spoolDir            string
+	spoolMaxSize        int64
+	spoolSegmentSize    int64
+	spoolMaxAge         time.Duration
+	spoolRetryInterval  time.Duration
+	asyncEnabled        bool
+	asyncMaxSends       int
+	asyncEnqueueTimeout time.Duration
 
 	bufferPool   = util.NewBufferPool()
 	bufferPool33 = util.NewBufferPool33()
@@ -66,12 +82,27 @@ type topicSettings struct {
 		source int
 		target int
 	}
+
+	// the settings below can only be set through the routing file
+	orgs          map[int]struct{}
+	excludeOrgs   map[int]struct{}
+	prefixes      []string
+	nameRegex     *regexp.Regexp
+	discardRegex  *regexp.Regexp
+	tagMatchers   []tagMatcher
+	orgIdRewrites map[int]int
 }
 
 type mtPublisher struct {
-	schemas      *conf.Schemas
 	autoInterval bool
-	topics       []topicSettings
+
+	// schemas and topics may be swapped out by reloads while publishing
+	lock    sync.RWMutex
+	schemas *conf.Schemas
+	topics  []topicSettings
+
+	// numPartitions looks up the number of partitions of a topic
+	numPartitions func(topic string) (int32, error)
 }
 
 type Partitioner interface {
@@ -83,7 +114,7 @@ func init() {
 	flag.StringVar(&rewriteOrgIdStr, "rewrite-org-id", "", "rewrite org id; for example 33:45 means rewriting org id 33 into 45 (may be given multiple times, if given then there must be exactly one per topic, as a comma-separated list)")
 	flag.Var(&onlyOrgIds, "only-org-id", "restrict publishing data belonging to org id; 0 means no restriction (may be given multiple times, once per topic, as a comma-separated list)")
 	flag.StringVar(&discardPrefixesStr, "discard-prefixes", "", "discard data points starting with one of the given prefixes separated by | (may be given multiple times, once per topic, as a comma-separated list)")
-	flag.StringVar(&codec, "metrics-kafka-comp", "snappy", "compression: none|gzip|snappy")
+	flag.StringVar(&codec, "metrics-kafka-comp", "snappy", "compression: none|gzip|snappy|lz4|zstd. lz4 requires kafka-version 0.10.0.0 or newer, zstd requires 2.1.0.0 or newer")
 	flag.BoolVar(&enabled, "metrics-publish", false, "enable metric publishing")
 	flag.StringVar(&partitionSchemesStr, "metrics-partition-scheme", "bySeries", "method used for partitioning metrics. (byOrg|bySeries|bySeriesWithTags|bySeriesWithTagsFnv) (may be given multiple times, once per topic, as a comma-separated list)")
 	flag.DurationVar(&flushFreq, "metrics-flush-freq", time.Millisecond*50, "The best-effort frequency of flushes to kafka")
@@ -97,19 +128,38 @@ func init() {
 	flag.StringVar(&tlsClientKey, "kafka-ssl-clientkey", "", "client key use for auth")
 	flag.DurationVar(&v2ClearInterval, "v2-clear-interval", time.Hour, "interval after which we always resend a full MetricData")
 	flag.StringVar(&kafkaVersionStr, "kafka-version", "0.10.0.0", "Kafka version in semver format. All brokers must be this version or newer.")
+	flag.BoolVar(&asyncEnabled, "kafka-async", false, "use an asynchronous producer, which batches the messages of concurrent requests. Requests still wait for their messages to be acked, and are rejected once kafka-async-max-concurrent-sends requests are waiting")
+	flag.IntVar(&asyncMaxSends, "kafka-async-max-concurrent-sends", 100, "maximum number of requests concurrently publishing, i.e. waiting for their messages to be acked, when using the asynchronous producer")
+	flag.DurationVar(&asyncEnqueueTimeout, "kafka-async-enqueue-timeout", 0, "how long to wait for one of the kafka-async-max-concurrent-sends to finish before rejecting a request, when using the asynchronous producer")
+	flag.StringVar(&spoolDir, "kafka-spool-dir", "", "directory to spool batches to when publishing to kafka fails. They are replayed in order once kafka recovers. Empty disables spooling")
+	flag.Int64Var(&spoolMaxSize, "kafka-spool-max-size", 1<<30, "maximum size in bytes of the spool. Once full, failed batches are no longer spooled")
+	flag.Int64Var(&spoolSegmentSize, "kafka-spool-segment-size", 64<<20, "size in bytes after which a new spool segment file is started")
+	flag.DurationVar(&spoolMaxAge, "kafka-spool-max-age", 24*time.Hour, "maximum age of spooled batches. Older batches are dropped instead of replayed")
+	flag.DurationVar(&spoolRetryInterval, "kafka-spool-retry-interval", 5*time.Second, "time to wait before retrying to replay a spooled batch after a failure")
 }
 
-func getCompression(codec string) sarama.CompressionCodec {
+// getCompression returns the codec for the given name, making sure the
+// configured kafka version is new enough to support it.
+func getCompression(codec string, kafkaVersion sarama.KafkaVersion) (sarama.CompressionCodec, error) {
 	switch codec {
 	case "none":
-		return sarama.CompressionNone
+		return sarama.CompressionNone, nil
 	case "gzip":
-		return sarama.CompressionGZIP
+		return sarama.CompressionGZIP, nil
 	case "snappy":
-		return sarama.CompressionSnappy
+		return sarama.CompressionSnappy, nil
+	case "lz4":
+		if !kafkaVersion.IsAtLeast(sarama.V0_10_0_0) {
+			return 0, fmt.Errorf("compression codec %q requires kafka-version 0.10.0.0 or newer", codec)
+		}
+		return sarama.CompressionLZ4, nil
+	case "zstd":
+		if !kafkaVersion.IsAtLeast(sarama.V2_1_0_0) {
+			return 0, fmt.Errorf("compression codec %q requires kafka-version 2.1.0.0 or newer", codec)
+		}
+		return sarama.CompressionZSTD, nil
 	default:
-		log.Fatalf("unknown compression codec %q", codec)
-		return 0 // make go compiler happy, needs a return *roll eyes*
+		return 0, fmt.Errorf("unknown compression codec %q", codec)
 	}
 }
 
@@ -177,20 +227,12 @@ func parseTopicSettings(partitionSchemesStr, topicsStr string, onlyOrgIds []int6
 		}
 
 		if len(rewriteOrgIdStrList) > 0 && rewriteOrgIdStrList[i] != "" {
-			parts := strings.Split(rewriteOrgIdStrList[i], ":")
-			if len(parts) != 2 {
-				return nil, errors.New("incorrect number of org ids in 'rewrite-org-id'")
-			}
-			source, err := strconv.ParseUint(parts[0], 10, 64)
+			source, target, err := parseOrgIdRewrite(rewriteOrgIdStrList[i])
 			if err != nil {
 				return nil, err
 			}
-			topic.orgIdRewrite.source = int(source)
-			target, err := strconv.ParseUint(parts[1], 10, 64)
-			if err != nil {
-				return nil, err
-			}
-			topic.orgIdRewrite.target = int(target)
+			topic.orgIdRewrite.source = source
+			topic.orgIdRewrite.target = target
 		}
 		topics = append(topics, topic)
 	}
@@ -230,9 +272,16 @@ func New(brokers []string, autoInterval bool) *mtPublisher {
 		autoInterval: autoInterval,
 	}
 
-	mp.topics, err = parseTopicSettings(partitionSchemesStr, topicsStr, onlyOrgIds, discardPrefixesStr, rewriteOrgIdStr)
-	if err != nil {
-		log.Fatalf("failed to initialize partitioner: %s", err)
+	if routingFile != "" {
+		mp.topics, err = loadRoutingFile(routingFile)
+		if err != nil {
+			log.Fatalf("failed to load routing file %s: %s", routingFile, err)
+		}
+	} else {
+		mp.topics, err = parseTopicSettings(partitionSchemesStr, topicsStr, onlyOrgIds, discardPrefixesStr, rewriteOrgIdStr)
+		if err != nil {
+			log.Fatalf("failed to initialize partitioner: %s", err)
+		}
 	}
 
 	if autoInterval {
@@ -249,7 +298,10 @@ func New(brokers []string, autoInterval bool) *mtPublisher {
 	config := sarama.NewConfig()
 	config.Producer.RequiredAcks = sarama.WaitForAll // Wait for all in-sync replicas to ack the message
 	config.Producer.Retry.Max = 10                   // Retry up to 10 times to produce the message
-	config.Producer.Compression = getCompression(codec)
+	config.Producer.Compression, err = getCompression(codec, kafkaVersion)
+	if err != nil {
+		log.Fatalf("invalid metrics-kafka-comp. %s", err)
+	}
 	config.Producer.Return.Successes = true
 	config.Producer.Flush.Frequency = flushFreq
 	config.Producer.Flush.MaxMessages = maxMessages
@@ -267,6 +319,11 @@ func New(brokers []string, autoInterval bool) *mtPublisher {
 		config.Net.TLS.Config.InsecureSkipVerify = tlsSkipVerify
 	}
 
+	err = configureSASL(config, kafkaVersion, saslMechanism, saslUsername, saslPassword, saslCredentialsFile)
+	if err != nil {
+		log.Fatalf("invalid kafka SASL config. %s", err)
+	}
+
 	err = config.Validate()
 	if err != nil {
 		log.Fatalf("failed to validate kafka config. %s", err)
@@ -277,29 +334,111 @@ func New(brokers []string, autoInterval bool) *mtPublisher {
 		log.Fatalf("failed to initialize kafka client %s", err)
 	}
 
-	for i, setting := range mp.topics {
-		partitions, err := client.Partitions(setting.name)
+	mp.numPartitions = func(topic string) (int32, error) {
+		partitions, err := client.Partitions(topic)
 		if err != nil {
-			log.Fatalf("failed to get number of partitions %s", err)
+			return 0, fmt.Errorf("failed to get number of partitions %s", err)
 		}
 		if len(partitions) < 1 {
-			log.Fatalf("failed to get number of partitions for topic %s", setting.name)
+			return 0, fmt.Errorf("failed to get number of partitions for topic %s", topic)
 		}
-		mp.topics[i].numPartitions = int32(len(partitions))
+		return int32(len(partitions)), nil
+	}
+	if err := mp.setNumPartitions(mp.topics); err != nil {
+		log.Fatal(err)
 	}
 
-	producer, err = sarama.NewSyncProducerFromClient(client)
-	if err != nil {
-		log.Fatalf("failed to initialize kafka producer. %s", err)
+	if asyncEnabled {
+		if asyncMaxSends < 1 {
+			log.Fatalf("kafka-async-max-concurrent-sends must be at least 1")
+		}
+		asyncProd, err := sarama.NewAsyncProducerFromClient(client)
+		if err != nil {
+			log.Fatalf("failed to initialize kafka producer. %s", err)
+		}
+		producer = newAsyncProducer(asyncProd, asyncMaxSends, asyncEnqueueTimeout)
+	} else {
+		producer, err = sarama.NewSyncProducerFromClient(client)
+		if err != nil {
+			log.Fatalf("failed to initialize kafka producer. %s", err)
+		}
 	}
 
 	if v2 {
 		keyCache = keycache.NewKeyCache(v2ClearInterval)
 	}
 
+	if spoolDir != "" {
+		pubSpool, err = newSpool(spoolDir, spoolMaxSize, spoolSegmentSize, spoolMaxAge)
+		if err != nil {
+			log.Fatalf("failed to initialize kafka spool. %s", err)
+		}
+		go pubSpool.replay(send, spoolRetryInterval)
+	}
+
+	go reportCompressionRatio(config.MetricRegistry, 10*time.Second)
+
 	return &mp
 }
 
+func (m *mtPublisher) setNumPartitions(topics []topicSettings) error {
+	for i := range topics {
+		numPartitions, err := m.numPartitions(topics[i].name)
+		if err != nil {
+			return err
+		}
+		topics[i].numPartitions = numPartitions
+	}
+	return nil
+}
+
+// ReloadRouting reloads the routing file and starts using the new topic settings.
+// If the file can't be loaded, the current settings are kept. Without a routing
+// file this is a no-op.
+func (m *mtPublisher) ReloadRouting() error {
+	if routingFile == "" {
+		return nil
+	}
+	topics, err := loadRoutingFile(routingFile)
+	if err != nil {
+		return fmt.Errorf("failed to load routing file %s: %s", routingFile, err)
+	}
+	if err := m.setNumPartitions(topics); err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.topics = topics
+	m.lock.Unlock()
+	log.Infof("reloaded routing file %s, publishing to %d topics", routingFile, len(topics))
+	return nil
+}
+
+// ReloadSchemas reloads the storage-schemas used to deduce the interval of
+// metrics. If the file can't be loaded, the current schemas are kept.
+func (m *mtPublisher) ReloadSchemas() error {
+	if !m.autoInterval {
+		return nil
+	}
+	schemas, err := getSchemas(schemasConf)
+	if err != nil {
+		return fmt.Errorf("failed to load schemas config. %s", err)
+	}
+	m.lock.Lock()
+	m.schemas = schemas
+	m.lock.Unlock()
+	return nil
+}
+
+// reportCompressionRatio periodically copies the mean compression ratio
+// that sarama tracks for produced record batches into our own stats.
+func reportCompressionRatio(registry metrics.Registry, interval time.Duration) {
+	for range time.Tick(interval) {
+		if h, ok := registry.Get("compression-ratio").(metrics.Histogram); ok {
+			compressionRatio.Set(int(h.Mean()))
+		}
+	}
+}
+
 type MetricDataType int
 
 const (
@@ -365,7 +504,12 @@ func (m *mtPublisher) Publish(metrics []*schema.MetricData) error {
 		return nil
 	}
 
-	if len(m.topics) == 0 {
+	m.lock.RLock()
+	topics := m.topics
+	schemas := m.schemas
+	m.lock.RUnlock()
+
+	if len(topics) == 0 {
 		return nil
 	}
 
@@ -373,7 +517,7 @@ func (m *mtPublisher) Publish(metrics []*schema.MetricData) error {
 
 	metricsCount := len(metrics)
 	// plan for a maximum of metrics*topics messages to be sent
-	payload := make([]*sarama.ProducerMessage, 0, metricsCount*len(m.topics))
+	payload := make([]*sarama.ProducerMessage, 0, metricsCount*len(topics))
 	pre := time.Now()
 	pubMD := make(map[string]int)
 	pubMP := make(map[string]int)
@@ -383,7 +527,7 @@ func (m *mtPublisher) Publish(metrics []*schema.MetricData) error {
 	for _, metric := range metrics {
 		if metric.Interval == 0 {
 			if m.autoInterval {
-				_, s := m.schemas.Match(metric.Name, 0)
+				_, s := schemas.Match(metric.Name, 0)
 				metric.Interval = s.Retentions[0].SecondsPerPoint
 				metric.SetId()
 			} else {
@@ -396,22 +540,16 @@ func (m *mtPublisher) Publish(metrics []*schema.MetricData) error {
 		// MetricData more than once per orgId
 		mdBufferCache := make(map[int]MetricDataBuffer)
 
-	TOPICS:
-		for _, topic := range m.topics {
-			for _, prefix := range topic.discardPrefixes {
-				if strings.HasPrefix(metric.Name, prefix) {
-					continue TOPICS
-				}
-			}
-
-			if topic.onlyOrgId != 0 && metric.OrgId != topic.onlyOrgId {
-				continue TOPICS
+		for i := range topics {
+			topic := &topics[i]
+			if !topic.accepts(metric) {
+				continue
 			}
 
 			// rewrite orgId if needed
 			originalOrgID := metric.OrgId
-			if metric.OrgId == topic.orgIdRewrite.source {
-				metric.OrgId = topic.orgIdRewrite.target
+			if targetOrgID := topic.targetOrgId(metric.OrgId); targetOrgID != metric.OrgId {
+				metric.OrgId = targetOrgID
 				metric.SetId()
 			}
 
@@ -467,22 +605,27 @@ func (m *mtPublisher) Publish(metrics []*schema.MetricData) error {
 		}
 	}()
 
-	err = producer.SendMessages(payload)
+	// while batches are pending in the spool, new batches are queued behind
+	// them, so that kafka receives all data in order.
+	if pubSpool != nil && !pubSpool.Empty() {
+		return pubSpool.Write(payload)
+	}
+
+	err = send(payload)
 	if err != nil {
-		if errors, ok := err.(sarama.ProducerErrors); ok {
-			sendErrProducer.Add(len(errors))
-			for i := 0; i < 10 && i < len(errors); i++ {
-				log.Errorf("SendMessages ProducerError %d/%d: %s", i, len(errors), errors[i].Error())
+		// backpressure is not a failure of kafka, so we let it surface to the client
+		if pubSpool != nil && err != publish.ErrBackpressure {
+			spoolErr := pubSpool.Write(payload)
+			if spoolErr == nil {
+				return nil
 			}
-		} else {
-			sendErrOther.Inc()
-			log.Errorf("SendMessages error: %s", err.Error())
+			log.Errorf("failed to spool batch of %d messages: %s", len(payload), spoolErr)
 		}
 		return err
 	}
 
 	publishDuration.Value(time.Since(pre))
-	for _, topic := range m.topics {
+	for _, topic := range topics {
 		pubTopicMD := pubMD[topic.name]
 		pubTopicMP := pubMP[topic.name]
 		pubTopicMPNO := pubMPNO[topic.name]
@@ -494,6 +637,25 @@ func (m *mtPublisher) Publish(metrics []*schema.MetricData) error {
 	return nil
 }
 
+// send sends the messages to kafka and accounts for any errors
+func send(payload []*sarama.ProducerMessage) error {
+	err := producer.SendMessages(payload)
+	if err != nil {
+		if err == publish.ErrBackpressure {
+			log.Debugf("SendMessages rejected batch of %d messages: %s", len(payload), err)
+		} else if errors, ok := err.(sarama.ProducerErrors); ok {
+			sendErrProducer.Add(len(errors))
+			for i := 0; i < 10 && i < len(errors); i++ {
+				log.Errorf("SendMessages ProducerError %d/%d: %s", i, len(errors), errors[i].Error())
+			}
+		} else {
+			sendErrOther.Inc()
+			log.Errorf("SendMessages error: %s", err.Error())
+		}
+	}
+	return err
+}
+
 func (*mtPublisher) Type() string {
 	return "Metrictank"
 }
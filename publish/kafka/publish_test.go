@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/Shopify/sarama"
 	"github.com/Shopify/sarama/mocks"
 	"github.com/grafana/metrictank/cluster/partitioner"
 	"github.com/grafana/metrictank/schema"
@@ -472,6 +473,36 @@ func Test_parseTopicSettings(t *testing.T) {
 	}
 }
 
+func Test_getCompression(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    string
+		version  sarama.KafkaVersion
+		expected sarama.CompressionCodec
+		wantErr  bool
+	}{
+		{name: "none", codec: "none", version: sarama.V0_10_0_0, expected: sarama.CompressionNone},
+		{name: "gzip", codec: "gzip", version: sarama.V0_10_0_0, expected: sarama.CompressionGZIP},
+		{name: "snappy", codec: "snappy", version: sarama.V0_10_0_0, expected: sarama.CompressionSnappy},
+		{name: "lz4", codec: "lz4", version: sarama.V0_10_0_0, expected: sarama.CompressionLZ4},
+		{name: "lz4_old_kafka", codec: "lz4", version: sarama.V0_9_0_0, wantErr: true},
+		{name: "zstd", codec: "zstd", version: sarama.V2_1_0_0, expected: sarama.CompressionZSTD},
+		{name: "zstd_old_kafka", codec: "zstd", version: sarama.V2_0_0_0, wantErr: true},
+		{name: "unknown", codec: "brotli", version: sarama.V2_1_0_0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getCompression(tt.codec, tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCompression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.expected {
+				t.Fatalf("getCompression() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
 func methodToString(m schema.PartitionByMethod) string {
 	switch m {
 	case schema.PartitionByOrg:
@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	p "github.com/grafana/metrictank/cluster/partitioner"
+	"github.com/grafana/metrictank/schema"
+	"github.com/pelletier/go-toml"
+)
+
+var routingFile string
+
+func init() {
+	flag.StringVar(&routingFile, "metrics-routing-file", "", "path to a toml file with the topics to publish to and the rules routing metrics to them. When set, it takes precedence over metrics-topic, metrics-partition-scheme, only-org-id, discard-prefixes and rewrite-org-id. Reloaded on SIGHUP")
+}
+
+// routingConfig is the format of the routing file, e.g.:
+//
+//	[[topic]]
+//	name = "mdm"
+//	partition-scheme = "bySeries"
+//	orgs = [1, 2]                     # only publish these orgs. empty means all orgs
+//	exclude-orgs = [3]                # never publish these orgs
+//	prefixes = ["prod."]              # only publish names starting with one of these
+//	discard-prefixes = ["prod.test."] # never publish names starting with one of these
+//	name-regex = "^prod\\."           # only publish names matching this regex
+//	discard-regex = "\\.tmp$"         # never publish names matching this regex
+//	tags = ["env=prod", "dc!~test.*"] # only publish series matching all of these
+//	rewrite-org-ids = ["33:45"]       # publish data of org 33 as org 45
+type routingConfig struct {
+	Topics []routingTopic `toml:"topic"`
+}
+
+type routingTopic struct {
+	Name            string   `toml:"name"`
+	PartitionScheme string   `toml:"partition-scheme"`
+	Orgs            []int    `toml:"orgs"`
+	ExcludeOrgs     []int    `toml:"exclude-orgs"`
+	Prefixes        []string `toml:"prefixes"`
+	DiscardPrefixes []string `toml:"discard-prefixes"`
+	NameRegex       string   `toml:"name-regex"`
+	DiscardRegex    string   `toml:"discard-regex"`
+	Tags            []string `toml:"tags"`
+	RewriteOrgIds   []string `toml:"rewrite-org-ids"`
+}
+
+type tagMatchType int
+
+const (
+	tagMatchEqual tagMatchType = iota
+	tagMatchNotEqual
+	tagMatchRegex
+	tagMatchNotRegex
+)
+
+// tagMatcher matches a tag of a series. Series that don't have the tag
+// are treated as having it with an empty value, like Prometheus does.
+type tagMatcher struct {
+	key       string
+	value     string
+	matchType tagMatchType
+	re        *regexp.Regexp
+}
+
+func parseTagMatcher(expr string) (tagMatcher, error) {
+	var m tagMatcher
+	for _, op := range []struct {
+		token     string
+		matchType tagMatchType
+	}{
+		{"!=", tagMatchNotEqual},
+		{"=~", tagMatchRegex},
+		{"!~", tagMatchNotRegex},
+		{"=", tagMatchEqual},
+	} {
+		pos := strings.Index(expr, op.token)
+		if pos < 1 {
+			continue
+		}
+		m.key = expr[:pos]
+		m.value = expr[pos+len(op.token):]
+		m.matchType = op.matchType
+		if m.matchType == tagMatchRegex || m.matchType == tagMatchNotRegex {
+			re, err := regexp.Compile("^(?:" + m.value + ")$")
+			if err != nil {
+				return m, fmt.Errorf("invalid regex in tag matcher %q: %s", expr, err)
+			}
+			m.re = re
+		}
+		return m, nil
+	}
+	return m, fmt.Errorf("invalid tag matcher %q", expr)
+}
+
+func (m tagMatcher) matches(tags []string) bool {
+	value := ""
+	for _, tag := range tags {
+		if strings.HasPrefix(tag, m.key) && len(tag) > len(m.key) && tag[len(m.key)] == '=' {
+			value = tag[len(m.key)+1:]
+			break
+		}
+	}
+	switch m.matchType {
+	case tagMatchEqual:
+		return value == m.value
+	case tagMatchNotEqual:
+		return value != m.value
+	case tagMatchRegex:
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// parseOrgIdRewrite parses a rewrite in the form "<source>:<target>"
+func parseOrgIdRewrite(rewrite string) (int, int, error) {
+	parts := strings.Split(rewrite, ":")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("incorrect number of org ids in 'rewrite-org-id'")
+	}
+	source, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	target, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(source), int(target), nil
+}
+
+func loadRoutingFile(path string) ([]topicSettings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseRouting(data)
+}
+
+func parseRouting(data []byte) ([]topicSettings, error) {
+	var conf routingConfig
+	if err := toml.Unmarshal(data, &conf); err != nil {
+		return nil, err
+	}
+	if len(conf.Topics) == 0 {
+		return nil, errors.New("no topics defined")
+	}
+
+	topics := make([]topicSettings, 0, len(conf.Topics))
+	seen := make(map[string]struct{})
+	for _, rt := range conf.Topics {
+		if rt.Name == "" {
+			return nil, errors.New("topic without name")
+		}
+		if _, ok := seen[rt.Name]; ok {
+			return nil, fmt.Errorf("topic %s: defined more than once", rt.Name)
+		}
+		seen[rt.Name] = struct{}{}
+
+		if rt.PartitionScheme == "" {
+			rt.PartitionScheme = "bySeries"
+		}
+		partitioner, err := p.NewKafka(rt.PartitionScheme)
+		if err != nil {
+			return nil, fmt.Errorf("topic %s: %s", rt.Name, err)
+		}
+
+		topic := topicSettings{
+			name:            rt.Name,
+			partitioner:     partitioner,
+			prefixes:        rt.Prefixes,
+			discardPrefixes: rt.DiscardPrefixes,
+		}
+		if len(rt.Orgs) > 0 {
+			topic.orgs = make(map[int]struct{}, len(rt.Orgs))
+			for _, org := range rt.Orgs {
+				topic.orgs[org] = struct{}{}
+			}
+		}
+		if len(rt.ExcludeOrgs) > 0 {
+			topic.excludeOrgs = make(map[int]struct{}, len(rt.ExcludeOrgs))
+			for _, org := range rt.ExcludeOrgs {
+				topic.excludeOrgs[org] = struct{}{}
+			}
+		}
+		if rt.NameRegex != "" {
+			topic.nameRegex, err = regexp.Compile(rt.NameRegex)
+			if err != nil {
+				return nil, fmt.Errorf("topic %s: invalid name-regex: %s", rt.Name, err)
+			}
+		}
+		if rt.DiscardRegex != "" {
+			topic.discardRegex, err = regexp.Compile(rt.DiscardRegex)
+			if err != nil {
+				return nil, fmt.Errorf("topic %s: invalid discard-regex: %s", rt.Name, err)
+			}
+		}
+		for _, expr := range rt.Tags {
+			m, err := parseTagMatcher(expr)
+			if err != nil {
+				return nil, fmt.Errorf("topic %s: %s", rt.Name, err)
+			}
+			topic.tagMatchers = append(topic.tagMatchers, m)
+		}
+		if len(rt.RewriteOrgIds) > 0 {
+			topic.orgIdRewrites = make(map[int]int, len(rt.RewriteOrgIds))
+			for _, rewrite := range rt.RewriteOrgIds {
+				source, target, err := parseOrgIdRewrite(rewrite)
+				if err != nil {
+					return nil, fmt.Errorf("topic %s: %s", rt.Name, err)
+				}
+				topic.orgIdRewrites[source] = target
+			}
+		}
+		topics = append(topics, topic)
+	}
+	return topics, nil
+}
+
+// accepts returns whether the metric should be published to the topic
+func (t *topicSettings) accepts(metric *schema.MetricData) bool {
+	if t.onlyOrgId != 0 && metric.OrgId != t.onlyOrgId {
+		return false
+	}
+	if t.orgs != nil {
+		if _, ok := t.orgs[metric.OrgId]; !ok {
+			return false
+		}
+	}
+	if _, ok := t.excludeOrgs[metric.OrgId]; ok {
+		return false
+	}
+	for _, prefix := range t.discardPrefixes {
+		if strings.HasPrefix(metric.Name, prefix) {
+			return false
+		}
+	}
+	if len(t.prefixes) > 0 {
+		matched := false
+		for _, prefix := range t.prefixes {
+			if strings.HasPrefix(metric.Name, prefix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if t.nameRegex != nil && !t.nameRegex.MatchString(metric.Name) {
+		return false
+	}
+	if t.discardRegex != nil && t.discardRegex.MatchString(metric.Name) {
+		return false
+	}
+	for _, m := range t.tagMatchers {
+		if !m.matches(metric.Tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// targetOrgId returns the org id the metric of the given org should be published as
+func (t *topicSettings) targetOrgId(orgId int) int {
+	if target, ok := t.orgIdRewrites[orgId]; ok {
+		return target
+	}
+	if orgId == t.orgIdRewrite.source {
+		return t.orgIdRewrite.target
+	}
+	return orgId
+}
@@ -0,0 +1,150 @@
+package kafka
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/grafana/metrictank/schema"
+)
+
+func Test_parseRouting(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		topics  []string
+		wantErr bool
+	}{
+		{name: "empty", data: "", wantErr: true},
+		{name: "no_name", data: "[[topic]]\npartition-scheme = \"byOrg\"\n", wantErr: true},
+		{name: "duplicate_topic", data: "[[topic]]\nname = \"mdm\"\n[[topic]]\nname = \"mdm\"\n", wantErr: true},
+		{name: "invalid_partition_scheme", data: "[[topic]]\nname = \"mdm\"\npartition-scheme = \"byMoon\"\n", wantErr: true},
+		{name: "invalid_regex", data: "[[topic]]\nname = \"mdm\"\nname-regex = \"(\"\n", wantErr: true},
+		{name: "invalid_tag_matcher", data: "[[topic]]\nname = \"mdm\"\ntags = [\"env\"]\n", wantErr: true},
+		{name: "invalid_rewrite", data: "[[topic]]\nname = \"mdm\"\nrewrite-org-ids = [\"1:2:3\"]\n", wantErr: true},
+		{name: "invalid_org", data: "[[topic]]\nname = \"mdm\"\norgs = [\"one\"]\n", wantErr: true},
+		{name: "single_topic", data: "[[topic]]\nname = \"mdm\"\n", topics: []string{"mdm"}},
+		{
+			name: "two_topics",
+			data: `
+[[topic]]
+name = "mdm"
+partition-scheme = "byOrg"
+orgs = [1, 2]
+
+[[topic]]
+name = "mdm-prod"
+tags = ["env=prod"]
+rewrite-org-ids = ["1:10"]
+`,
+			topics: []string{"mdm", "mdm-prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRouting([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRouting() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.topics) {
+				t.Fatalf("parseRouting() returned %d topics, expected %d", len(got), len(tt.topics))
+			}
+			for i, topic := range got {
+				if topic.name != tt.topics[i] {
+					t.Fatalf("parseRouting(): incorrect topic name %s, expects %s", topic.name, tt.topics[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_topicSettingsAccepts(t *testing.T) {
+	topics, err := parseRouting([]byte(`
+[[topic]]
+name = "orgs"
+orgs = [1, 2]
+exclude-orgs = [2]
+
+[[topic]]
+name = "prefixes"
+prefixes = ["a.", "b."]
+discard-prefixes = ["a.tmp."]
+
+[[topic]]
+name = "regex"
+name-regex = "^a\\."
+discard-regex = "\\.tmp$"
+
+[[topic]]
+name = "tags"
+tags = ["env=prod", "dc!=test", "host=~web-.*", "role!~db|cache"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]*topicSettings)
+	for i := range topics {
+		byName[topics[i].name] = &topics[i]
+	}
+
+	tests := []struct {
+		topic    string
+		metric   schema.MetricData
+		expected bool
+	}{
+		{"orgs", schema.MetricData{Name: "a.b", OrgId: 1}, true},
+		{"orgs", schema.MetricData{Name: "a.b", OrgId: 2}, false},
+		{"orgs", schema.MetricData{Name: "a.b", OrgId: 3}, false},
+		{"prefixes", schema.MetricData{Name: "a.b", OrgId: 1}, true},
+		{"prefixes", schema.MetricData{Name: "b.b", OrgId: 1}, true},
+		{"prefixes", schema.MetricData{Name: "c.b", OrgId: 1}, false},
+		{"prefixes", schema.MetricData{Name: "a.tmp.b", OrgId: 1}, false},
+		{"regex", schema.MetricData{Name: "a.b", OrgId: 1}, true},
+		{"regex", schema.MetricData{Name: "b.a.b", OrgId: 1}, false},
+		{"regex", schema.MetricData{Name: "a.b.tmp", OrgId: 1}, false},
+		{"tags", schema.MetricData{Name: "a", OrgId: 1, Tags: []string{"env=prod", "host=web-1"}}, true},
+		{"tags", schema.MetricData{Name: "a", OrgId: 1, Tags: []string{"env=prod", "host=web-1", "dc=test"}}, false},
+		{"tags", schema.MetricData{Name: "a", OrgId: 1, Tags: []string{"env=prod", "host=db-1"}}, false},
+		{"tags", schema.MetricData{Name: "a", OrgId: 1, Tags: []string{"env=prod", "host=web-1", "role=cache"}}, false},
+		{"tags", schema.MetricData{Name: "a", OrgId: 1, Tags: []string{"environment=prod", "host=web-1"}}, false},
+		{"tags", schema.MetricData{Name: "a", OrgId: 1}, false},
+	}
+	for _, tt := range tests {
+		if got := byName[tt.topic].accepts(&tt.metric); got != tt.expected {
+			t.Errorf("topic %s: accepts(%s %d %v) = %t, expected %t", tt.topic, tt.metric.Name, tt.metric.OrgId, tt.metric.Tags, got, tt.expected)
+		}
+	}
+}
+
+func TestReloadRouting(t *testing.T) {
+	f, err := ioutil.TempFile("", "routing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("[[topic]]\nname = \"mdm\"\n")
+	f.Close()
+
+	origRoutingFile := routingFile
+	routingFile = f.Name()
+	defer func() { routingFile = origRoutingFile }()
+
+	m := &mtPublisher{
+		numPartitions: func(topic string) (int32, error) { return 8, nil },
+	}
+	if err := m.ReloadRouting(); err != nil {
+		t.Fatalf("ReloadRouting() returned error: %s", err)
+	}
+	if len(m.topics) != 1 || m.topics[0].name != "mdm" || m.topics[0].numPartitions != 8 {
+		t.Fatalf("unexpected topics after reload: %+v", m.topics)
+	}
+
+	// an invalid file must keep the current routing
+	ioutil.WriteFile(f.Name(), []byte("[[topic]]\nname = \"\"\n"), 0644)
+	if err := m.ReloadRouting(); err == nil {
+		t.Fatalf("expected ReloadRouting() to fail on invalid file")
+	}
+	if len(m.topics) != 1 || m.topics[0].name != "mdm" {
+		t.Fatalf("expected routing to be kept after failed reload, got %+v", m.topics)
+	}
+}
@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/ini.v1"
+)
+
+var (
+	saslMechanism       string
+	saslUsername        string
+	saslPassword        string
+	saslCredentialsFile string
+)
+
+func init() {
+	flag.StringVar(&saslMechanism, "kafka-sasl-mechanism", "", "SASL mechanism to authenticate to kafka with. (PLAIN|SCRAM-SHA-256|SCRAM-SHA-512) empty disables SASL")
+	flag.StringVar(&saslUsername, "kafka-sasl-username", "", "username for SASL authentication to kafka")
+	flag.StringVar(&saslPassword, "kafka-sasl-password", "", "password for SASL authentication to kafka")
+	flag.StringVar(&saslCredentialsFile, "kafka-sasl-credentials-file", "", "path to ini file with a username and password key for SASL authentication to kafka. Can not be combined with kafka-sasl-username/kafka-sasl-password")
+}
+
+// readSASLCredentials reads the username and password from an ini file like:
+//
+// username = tsdb-gw
+// password = secret
+func readSASLCredentials(file string) (string, string, error) {
+	conf, err := ini.Load(file)
+	if err != nil {
+		return "", "", err
+	}
+	section := conf.Section("")
+	return section.Key("username").String(), section.Key("password").String(), nil
+}
+
+// configureSASL applies the SASL settings to the kafka config, validating
+// that they make sense together with the other settings.
+func configureSASL(config *sarama.Config, kafkaVersion sarama.KafkaVersion, mechanism, username, password, credentialsFile string) error {
+	if mechanism == "" {
+		if username != "" || password != "" || credentialsFile != "" {
+			return errors.New("SASL credentials given but no kafka-sasl-mechanism set")
+		}
+		return nil
+	}
+
+	if credentialsFile != "" {
+		if username != "" || password != "" {
+			return errors.New("kafka-sasl-credentials-file can not be combined with kafka-sasl-username or kafka-sasl-password")
+		}
+		var err error
+		username, password, err = readSASLCredentials(credentialsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read SASL credentials file: %s", err)
+		}
+	}
+	if username == "" || password == "" {
+		return errors.New("SASL requires both a username and a password")
+	}
+
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = username
+	config.Net.SASL.Password = password
+	if kafkaVersion.IsAtLeast(sarama.V1_0_0_0) {
+		config.Net.SASL.Version = sarama.SASLHandshakeV1
+	}
+
+	switch sarama.SASLMechanism(mechanism) {
+	case sarama.SASLTypePlaintext:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+		if !config.Net.TLS.Enable {
+			log.Warn("SASL PLAIN is used without kafka-ssl, the kafka password will be sent in cleartext")
+		}
+	case sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+		if !kafkaVersion.IsAtLeast(sarama.V1_0_0_0) {
+			return fmt.Errorf("SASL mechanism %s requires kafka-version 1.0.0 or newer", mechanism)
+		}
+		config.Net.SASL.Mechanism = sarama.SASLMechanism(mechanism)
+		config.Net.SASL.SCRAMClientGeneratorFunc = newSCRAMClient(config.Net.SASL.Mechanism)
+	default:
+		return fmt.Errorf("unknown SASL mechanism %q", mechanism)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+func TestConfigureSASL(t *testing.T) {
+	credsFile, err := ioutil.TempFile("", "sasl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(credsFile.Name())
+	credsFile.WriteString("username = fileuser\npassword = filepass\n")
+	credsFile.Close()
+
+	tests := []struct {
+		name      string
+		version   sarama.KafkaVersion
+		mechanism string
+		username  string
+		password  string
+		credsFile string
+		wantErr   bool
+		wantUser  string
+		wantPass  string
+	}{
+		{name: "disabled", version: sarama.V0_10_0_0},
+		{name: "credentials without mechanism", version: sarama.V0_10_0_0, username: "user", password: "pass", wantErr: true},
+		{name: "plain", version: sarama.V0_10_0_0, mechanism: "PLAIN", username: "user", password: "pass", wantUser: "user", wantPass: "pass"},
+		{name: "plain without password", version: sarama.V0_10_0_0, mechanism: "PLAIN", username: "user", wantErr: true},
+		{name: "unknown mechanism", version: sarama.V1_0_0_0, mechanism: "GSSAPI", username: "user", password: "pass", wantErr: true},
+		{name: "scram on old kafka", version: sarama.V0_10_0_0, mechanism: "SCRAM-SHA-256", username: "user", password: "pass", wantErr: true},
+		{name: "scram-sha-512", version: sarama.V1_0_0_0, mechanism: "SCRAM-SHA-512", username: "user", password: "pass", wantUser: "user", wantPass: "pass"},
+		{name: "credentials file", version: sarama.V1_0_0_0, mechanism: "SCRAM-SHA-256", credsFile: credsFile.Name(), wantUser: "fileuser", wantPass: "filepass"},
+		{name: "credentials file and password", version: sarama.V1_0_0_0, mechanism: "SCRAM-SHA-256", password: "pass", credsFile: credsFile.Name(), wantErr: true},
+		{name: "missing credentials file", version: sarama.V1_0_0_0, mechanism: "PLAIN", credsFile: credsFile.Name() + ".missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := sarama.NewConfig()
+			err := configureSASL(config, tt.version, tt.mechanism, tt.username, tt.password, tt.credsFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("configureSASL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if config.Net.SASL.Enable != (tt.mechanism != "") {
+				t.Fatalf("expected SASL enabled to be %t", tt.mechanism != "")
+			}
+			if config.Net.SASL.User != tt.wantUser || config.Net.SASL.Password != tt.wantPass {
+				t.Fatalf("expected credentials %s/%s, got %s/%s", tt.wantUser, tt.wantPass, config.Net.SASL.User, config.Net.SASL.Password)
+			}
+			if config.Net.SASL.Enable {
+				if err := config.Validate(); err != nil {
+					t.Fatalf("config.Validate() returned error: %s", err)
+				}
+			}
+		})
+	}
+}
+
+// test vector from RFC 7677 section 3
+func TestSCRAMClient(t *testing.T) {
+	c := &scramClient{
+		hashGen: scram.SHA256,
+		nonce:   func() string { return "rOprNGfwEbeRWgbNEkqO" },
+	}
+	if err := c.Begin("user", "pencil", ""); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		challenge string
+		response  string
+	}{
+		{"", "n,,n=user,r=rOprNGfwEbeRWgbNEkqO"},
+		{"r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="},
+		{"v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", ""},
+	}
+	for i, step := range steps {
+		if c.Done() {
+			t.Fatalf("step %d: exchange completed early", i)
+		}
+		response, err := c.Step(step.challenge)
+		if err != nil {
+			t.Fatalf("step %d: Step() returned error: %s", i, err)
+		}
+		if response != step.response {
+			t.Fatalf("step %d: expected response %q, got %q", i, step.response, response)
+		}
+	}
+	if !c.Done() {
+		t.Fatalf("expected exchange to be completed")
+	}
+
+	// a server that does not know the password must be rejected
+	c.Begin("user", "pencil", "")
+	c.Step(steps[0].challenge)
+	c.Step(steps[1].challenge)
+	if _, err := c.Step("v=AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="); err == nil {
+		t.Fatalf("expected invalid server signature to be rejected")
+	}
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// scramClient implements sarama.SCRAMClient on top of github.com/xdg-go/scram
+type scramClient struct {
+	hashGen scram.HashGeneratorFcn
+	nonce   scram.NonceGeneratorFcn // nil means random nonces
+
+	conv *scram.ClientConversation
+}
+
+func newSCRAMClient(mechanism sarama.SASLMechanism) func() sarama.SCRAMClient {
+	hashGen := scram.SHA256
+	if mechanism == sarama.SASLTypeSCRAMSHA512 {
+		hashGen = scram.SHA512
+	}
+	return func() sarama.SCRAMClient {
+		return &scramClient{hashGen: hashGen}
+	}
+}
+
+func (c *scramClient) Begin(username, password, authzID string) error {
+	client, err := c.hashGen.NewClient(username, password, authzID)
+	if err != nil {
+		return err
+	}
+	if c.nonce != nil {
+		client = client.WithNonceGenerator(c.nonce)
+	}
+	c.conv = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conv.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conv.Done()
+}
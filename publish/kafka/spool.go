@@ -0,0 +1,476 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/grafana/metrictank/stats"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	spoolBatches        = stats.NewGauge32("output.kafka.spool.batches")
+	spoolBytes          = stats.NewGauge64("output.kafka.spool.bytes")
+	spoolReplayLag      = stats.NewGauge32("output.kafka.spool.replay_lag")
+	spoolSpooled        = stats.NewCounterRate32("output.kafka.spool.spooled")
+	spoolReplayed       = stats.NewCounterRate32("output.kafka.spool.replayed")
+	spoolRejectedFull   = stats.NewCounterRate32("output.kafka.spool.rejected_full")
+	spoolDroppedExpired = stats.NewCounterRate32("output.kafka.spool.dropped_expired")
+	spoolDroppedCorrupt = stats.NewCounterRate32("output.kafka.spool.dropped_corrupt")
+
+	errSpoolFull    = errors.New("spool is full")
+	errSpoolCorrupt = errors.New("spool record is corrupt")
+	spoolCRCTable   = crc32.MakeTable(crc32.Castagnoli)
+)
+
+const (
+	spoolFileSuffix = ".spool"
+	spoolHeaderSize = int64(8)
+)
+
+// spool is a write-ahead spool on local disk, for batches that could not be published to kafka.
+// Batches are appended to segment files and replayed in the order they were written.
+// Fully replayed segments are removed. A segment that was only partially replayed when
+// the process stopped is replayed from its start again, so delivery is at-least-once.
+//
+// Each batch is stored as a record (integers are big endian):
+// uint32 payload length | uint32 crc32c of payload | payload
+// where the payload is:
+// int64 unix nano time of spooling | uint32 message count | messages
+// and each message is:
+// uint16 topic length | topic | int32 partition | uint32 value length | value
+type spool struct {
+	sync.Mutex
+	dir         string
+	maxSize     int64
+	maxAge      time.Duration
+	segmentSize int64
+
+	segments   []*segment // oldest first. new records are appended to the last one
+	writer     *os.File   // append handle of the last segment
+	reader     *os.File   // read handle of the first segment
+	readOffset int64      // offset of the next record to replay in the first segment
+	nextID     uint64
+	size       int64 // bytes pending replay
+	batches    int   // batches pending replay
+
+	notify chan struct{}
+}
+
+type segment struct {
+	id      uint64
+	path    string
+	size    int64
+	batches int
+}
+
+// newSpool opens the spool in the given directory, picking up any segments left by a previous run
+func newSpool(dir string, maxSize, segmentSize int64, maxAge time.Duration) (*spool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{
+		dir:         dir,
+		maxSize:     maxSize,
+		maxAge:      maxAge,
+		segmentSize: segmentSize,
+		notify:      make(chan struct{}, 1),
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), spoolFileSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), spoolFileSuffix), 10, 64)
+		if err != nil {
+			log.Warnf("kafka spool: ignoring unknown file %s", f.Name())
+			continue
+		}
+		seg := &segment{
+			id:   id,
+			path: filepath.Join(dir, f.Name()),
+		}
+		err = seg.scan()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan spool segment %s: %s", seg.path, err)
+		}
+		s.segments = append(s.segments, seg)
+		s.size += seg.size
+		s.batches += seg.batches
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].id < s.segments[j].id })
+	if len(s.segments) > 0 {
+		s.nextID = s.segments[len(s.segments)-1].id + 1
+		s.writer, err = os.OpenFile(s.segments[len(s.segments)-1].path, os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("kafka spool: found %d batches (%d bytes) to replay in %s", s.batches, s.size, dir)
+	}
+	s.updateStats()
+	return s, nil
+}
+
+// scan counts the records of the segment. A trailing partial record, which
+// is what is left when we crash halfway through a write, is truncated.
+func (seg *segment) scan() error {
+	f, err := os.OpenFile(seg.path, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var offset int64
+	header := make([]byte, spoolHeaderSize)
+	for offset+spoolHeaderSize <= info.Size() {
+		_, err := f.ReadAt(header, offset)
+		if err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		if offset+spoolHeaderSize+length > info.Size() {
+			break
+		}
+		offset += spoolHeaderSize + length
+		seg.batches++
+	}
+	if offset != info.Size() {
+		log.Warnf("kafka spool: truncating partial record at the end of %s", seg.path)
+		err = f.Truncate(offset)
+		if err != nil {
+			return err
+		}
+	}
+	seg.size = offset
+	return nil
+}
+
+// Empty returns whether there are no batches pending replay
+func (s *spool) Empty() bool {
+	s.Lock()
+	empty := s.batches == 0
+	s.Unlock()
+	return empty
+}
+
+// Write durably appends the batch to the spool
+func (s *spool) Write(payload []*sarama.ProducerMessage) error {
+	record, err := encodeSpoolRecord(payload, time.Now())
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.size+int64(len(record)) > s.maxSize {
+		spoolRejectedFull.Inc()
+		return errSpoolFull
+	}
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].size >= s.segmentSize {
+		err = s.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	seg := s.segments[len(s.segments)-1]
+	n, err := s.writer.Write(record)
+	if err == nil {
+		err = s.writer.Sync()
+	}
+	if err != nil {
+		// make sure we don't leave a partial record behind that the next write would append to
+		if n > 0 {
+			s.writer.Truncate(seg.size)
+		}
+		return err
+	}
+	seg.size += int64(n)
+	seg.batches++
+	s.size += int64(n)
+	s.batches++
+	spoolSpooled.Inc()
+	s.updateStats()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// rotate starts a new segment to append to
+func (s *spool) rotate() error {
+	seg := &segment{
+		id:   s.nextID,
+		path: filepath.Join(s.dir, fmt.Sprintf("%020d%s", s.nextID, spoolFileSuffix)),
+	}
+	f, err := os.OpenFile(seg.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if s.writer != nil {
+		s.writer.Close()
+	}
+	s.writer = f
+	s.nextID++
+	s.segments = append(s.segments, seg)
+	return nil
+}
+
+// peek returns the oldest pending batch, when it was spooled, and the size of its record.
+// It returns a nil batch if there is nothing to replay.
+// It returns errSpoolCorrupt if the segment is missing, shorter than expected
+// or the record fails its checksum or decoding. Other errors, like failed
+// reads, may be temporary.
+func (s *spool) peek() ([]*sarama.ProducerMessage, time.Time, int64, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.batches == 0 {
+		return nil, time.Time{}, 0, nil
+	}
+	seg := s.segments[0]
+	if s.reader == nil {
+		var err error
+		s.reader, err = os.Open(seg.path)
+		if err != nil {
+			s.reader = nil
+			if os.IsNotExist(err) {
+				return nil, time.Time{}, 0, errSpoolCorrupt
+			}
+			return nil, time.Time{}, 0, err
+		}
+	}
+
+	header := make([]byte, spoolHeaderSize)
+	err := s.readAt(header, s.readOffset)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if s.readOffset+spoolHeaderSize+length > seg.size {
+		return nil, time.Time{}, 0, errSpoolCorrupt
+	}
+	data := make([]byte, length)
+	err = s.readAt(data, s.readOffset+spoolHeaderSize)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	if crc32.Checksum(data, spoolCRCTable) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, time.Time{}, 0, errSpoolCorrupt
+	}
+	payload, spooled, err := decodeSpoolRecord(data)
+	if err != nil {
+		return nil, time.Time{}, 0, errSpoolCorrupt
+	}
+	return payload, spooled, spoolHeaderSize + length, nil
+}
+
+// readAt fills buf from the oldest segment. As the segment holds all records
+// we accounted for, reaching its end means it is corrupt. On other errors the
+// segment is reopened on the next read. The caller must hold the lock.
+func (s *spool) readAt(buf []byte, offset int64) error {
+	_, err := s.reader.ReadAt(buf, offset)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errSpoolCorrupt
+	}
+	if err != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	return err
+}
+
+// commit marks the oldest pending batch, of the given record size, as replayed
+func (s *spool) commit(size int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	seg := s.segments[0]
+	s.readOffset += size
+	s.size -= size
+	s.batches--
+	seg.batches--
+	if s.readOffset >= seg.size {
+		s.removeFirst()
+	}
+	s.updateStats()
+}
+
+// discardFirst drops everything that is left to replay in the oldest segment.
+// This is used when the segment turns out to be corrupt, as we can no longer
+// tell where its records start.
+func (s *spool) discardFirst() {
+	s.Lock()
+	defer s.Unlock()
+
+	seg := s.segments[0]
+	spoolDroppedCorrupt.Add(seg.batches)
+	s.size -= seg.size - s.readOffset
+	s.batches -= seg.batches
+	s.removeFirst()
+	s.updateStats()
+}
+
+// removeFirst removes the oldest segment. The caller must hold the lock.
+func (s *spool) removeFirst() {
+	seg := s.segments[0]
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	if len(s.segments) == 1 {
+		s.writer.Close()
+		s.writer = nil
+	}
+	err := os.Remove(seg.path)
+	if err != nil {
+		log.Errorf("kafka spool: failed to remove replayed segment %s: %s", seg.path, err)
+	}
+	s.segments = s.segments[1:]
+	s.readOffset = 0
+}
+
+// updateStats reports depth and size of the spool. The caller must hold the lock.
+func (s *spool) updateStats() {
+	spoolBatches.Set(s.batches)
+	spoolBytes.Set(int(s.size))
+	if s.batches == 0 {
+		spoolReplayLag.Set(0)
+	}
+}
+
+// replay sends spooled batches, oldest first, until they are all delivered.
+// When reading or sending fails, it retries the same batch after retryInterval.
+// It never returns.
+func (s *spool) replay(send func([]*sarama.ProducerMessage) error, retryInterval time.Duration) {
+	for {
+		payload, spooled, size, err := s.peek()
+		if err == errSpoolCorrupt {
+			log.Errorf("kafka spool: spooled batch is corrupt, discarding the rest of the segment")
+			s.discardFirst()
+			continue
+		}
+		if err != nil {
+			log.Warnf("kafka spool: failed to read spooled batch, retrying in %s: %s", retryInterval, err)
+			time.Sleep(retryInterval)
+			continue
+		}
+		if payload == nil {
+			<-s.notify
+			continue
+		}
+
+		age := time.Since(spooled)
+		spoolReplayLag.Set(int(age.Seconds()))
+		if age > s.maxAge {
+			log.Warnf("kafka spool: dropping batch of %d messages spooled %s ago", len(payload), age)
+			spoolDroppedExpired.Inc()
+			s.commit(size)
+			continue
+		}
+
+		err = send(payload)
+		if err != nil {
+			log.Warnf("kafka spool: failed to replay batch, retrying in %s: %s", retryInterval, err)
+			time.Sleep(retryInterval)
+			continue
+		}
+		spoolReplayed.Inc()
+		s.commit(size)
+	}
+}
+
+func encodeSpoolRecord(payload []*sarama.ProducerMessage, now time.Time) ([]byte, error) {
+	size := spoolHeaderSize + 12
+	values := make([][]byte, len(payload))
+	for i, m := range payload {
+		var err error
+		values[i], err = m.Value.Encode()
+		if err != nil {
+			return nil, err
+		}
+		size += int64(2 + len(m.Topic) + 4 + 4 + len(values[i]))
+	}
+
+	buf := make([]byte, spoolHeaderSize, size)
+	buf = appendUint64(buf, uint64(now.UnixNano()))
+	buf = appendUint32(buf, uint32(len(payload)))
+	for i, m := range payload {
+		buf = append(buf, byte(len(m.Topic)>>8), byte(len(m.Topic)))
+		buf = append(buf, m.Topic...)
+		buf = appendUint32(buf, uint32(m.Partition))
+		buf = appendUint32(buf, uint32(len(values[i])))
+		buf = append(buf, values[i]...)
+	}
+	data := buf[spoolHeaderSize:]
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(data, spoolCRCTable))
+	return buf, nil
+}
+
+func decodeSpoolRecord(data []byte) ([]*sarama.ProducerMessage, time.Time, error) {
+	if len(data) < 12 {
+		return nil, time.Time{}, io.ErrUnexpectedEOF
+	}
+	spooled := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+	count := binary.BigEndian.Uint32(data[8:])
+	data = data[12:]
+
+	payload := make([]*sarama.ProducerMessage, 0, count)
+	for i := uint32(0); i < count; i++ {
+		if len(data) < 2 {
+			return nil, spooled, io.ErrUnexpectedEOF
+		}
+		topicLen := int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if len(data) < topicLen+8 {
+			return nil, spooled, io.ErrUnexpectedEOF
+		}
+		topic := string(data[:topicLen])
+		data = data[topicLen:]
+		partition := int32(binary.BigEndian.Uint32(data))
+		valueLen := int(binary.BigEndian.Uint32(data[4:]))
+		data = data[8:]
+		if len(data) < valueLen {
+			return nil, spooled, io.ErrUnexpectedEOF
+		}
+		payload = append(payload, &sarama.ProducerMessage{
+			Topic:     topic,
+			Partition: partition,
+			Value:     sarama.ByteEncoder(data[:valueLen]),
+		})
+		data = data[valueLen:]
+	}
+	return payload, spooled, nil
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	return appendUint32(appendUint32(buf, uint32(v>>32)), uint32(v))
+}
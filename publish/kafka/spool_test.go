@@ -0,0 +1,184 @@
+package kafka
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func spoolTestBatch(i int) []*sarama.ProducerMessage {
+	return []*sarama.ProducerMessage{
+		{
+			Topic:     "testTopic1",
+			Partition: int32(i),
+			Value:     sarama.ByteEncoder("value" + strconv.Itoa(i)),
+		},
+		{
+			Topic:     "testTopic2",
+			Partition: int32(i + 1),
+			Value:     sarama.ByteEncoder("other" + strconv.Itoa(i)),
+		},
+	}
+}
+
+func checkSpoolBatch(t *testing.T, s *spool, i int) int64 {
+	payload, _, size, err := s.peek()
+	if err != nil {
+		t.Fatalf("peek() returned error: %s", err)
+	}
+	expected := spoolTestBatch(i)
+	if len(payload) != len(expected) {
+		t.Fatalf("batch %d: expected %d messages, got %d", i, len(expected), len(payload))
+	}
+	for j := range expected {
+		value, _ := payload[j].Value.Encode()
+		expectedValue, _ := expected[j].Value.Encode()
+		if payload[j].Topic != expected[j].Topic || payload[j].Partition != expected[j].Partition || string(value) != string(expectedValue) {
+			t.Fatalf("batch %d message %d: expected %s/%d/%s, got %s/%d/%s", i, j, expected[j].Topic, expected[j].Partition, expectedValue, payload[j].Topic, payload[j].Partition, value)
+		}
+	}
+	return size
+}
+
+func TestSpoolReplaysInOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// use tiny segments so that every batch ends up in its own segment
+	s, err := newSpool(dir, 1<<20, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := s.Write(spoolTestBatch(i)); err != nil {
+			t.Fatalf("Write() returned error: %s", err)
+		}
+	}
+	if len(s.segments) != 5 {
+		t.Fatalf("expected 5 segments, got %d", len(s.segments))
+	}
+
+	s.commit(checkSpoolBatch(t, s, 0))
+	s.commit(checkSpoolBatch(t, s, 1))
+
+	// reopening the spool must pick up where we left off
+	s, err = newSpool(dir, 1<<20, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.batches != 3 {
+		t.Fatalf("expected 3 batches after reopening, got %d", s.batches)
+	}
+	for i := 2; i < 5; i++ {
+		s.commit(checkSpoolBatch(t, s, i))
+	}
+	if !s.Empty() {
+		t.Fatalf("expected spool to be empty")
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+spoolFileSuffix))
+	if len(files) != 0 {
+		t.Fatalf("expected replayed segments to be removed, found %v", files)
+	}
+
+	// the spool must still be usable after it has been drained
+	if err := s.Write(spoolTestBatch(5)); err != nil {
+		t.Fatalf("Write() returned error: %s", err)
+	}
+	s.commit(checkSpoolBatch(t, s, 5))
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	record, _ := encodeSpoolRecord(spoolTestBatch(0), time.Now())
+	s, err := newSpool(dir, int64(len(record)*2), 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Write(spoolTestBatch(i)); err != nil {
+			t.Fatalf("Write() returned error: %s", err)
+		}
+	}
+	if err := s.Write(spoolTestBatch(2)); err != errSpoolFull {
+		t.Fatalf("expected errSpoolFull, got %v", err)
+	}
+}
+
+func TestSpoolTruncatesPartialRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 1<<20, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write(spoolTestBatch(0)); err != nil {
+		t.Fatalf("Write() returned error: %s", err)
+	}
+	// mimic a crash halfway through writing the second record
+	record, _ := encodeSpoolRecord(spoolTestBatch(1), time.Now())
+	s.writer.Write(record[:len(record)/2])
+
+	s, err = newSpool(dir, 1<<20, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.batches != 1 {
+		t.Fatalf("expected 1 batch after reopening, got %d", s.batches)
+	}
+	s.commit(checkSpoolBatch(t, s, 0))
+	if err := s.Write(spoolTestBatch(2)); err != nil {
+		t.Fatalf("Write() returned error: %s", err)
+	}
+	s.commit(checkSpoolBatch(t, s, 2))
+}
+
+func TestSpoolPeekErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 1<<20, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Write(spoolTestBatch(i)); err != nil {
+			t.Fatalf("Write() returned error: %s", err)
+		}
+	}
+
+	// a failed read is not corruption, and the next peek reads the segment again
+	s.commit(checkSpoolBatch(t, s, 0))
+	s.reader.Close()
+	if _, _, _, err := s.peek(); err == nil || err == errSpoolCorrupt {
+		t.Fatalf("expected a read error, got %v", err)
+	}
+	checkSpoolBatch(t, s, 1)
+
+	// a segment that is shorter than the records we spooled is corrupt
+	if err := os.Truncate(s.segments[0].path, s.segments[0].size-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := s.peek(); err != errSpoolCorrupt {
+		t.Fatalf("expected errSpoolCorrupt, got %v", err)
+	}
+}
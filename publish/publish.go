@@ -1,6 +1,7 @@
 package publish
 
 import (
+	"errors"
 	"strconv"
 
 	schema "github.com/grafana/metrictank/schema"
@@ -18,6 +19,10 @@ var (
 	}, []string{"org"})
 )
 
+// ErrBackpressure is returned by publishers that are temporarily unable to accept
+// more metrics. Clients should back off and retry.
+var ErrBackpressure = errors.New("publisher is overloaded, try again later")
+
 type Publisher interface {
 	Publish(metrics []*schema.MetricData) error
 	Type() string
@@ -0,0 +1,195 @@
+package metrictank
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/raintank/tsdb-gw/api/models"
+	"github.com/raintank/tsdb-gw/util"
+	log "github.com/sirupsen/logrus"
+)
+
+var readClient = &http.Client{}
+
+// renderSeries is a series of the json output of the metrictank render api
+type renderSeries struct {
+	Tags       map[string]string `json:"tags"`
+	Datapoints [][2]*float64     `json:"datapoints"` // value, timestamp in seconds. The value is null when missing
+}
+
+// PrometheusRead serves the prometheus remote read api. Each query is run as a
+// seriesByTag render request against metrictank, on behalf of the org of the user.
+func PrometheusRead(c *models.Context) {
+	if c.Req.Request.Body == nil {
+		c.JSON(400, "no data included in request.")
+		return
+	}
+	defer c.Req.Request.Body.Close()
+	compressed, err := ioutil.ReadAll(c.Req.Request.Body)
+	if err != nil {
+		c.JSON(400, err.Error())
+		return
+	}
+	reqBuf, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		c.JSON(400, err.Error())
+		return
+	}
+	var req prompb.ReadRequest
+	if err := proto.Unmarshal(reqBuf, &req); err != nil {
+		c.JSON(400, err.Error())
+		return
+	}
+
+	resp := prompb.ReadResponse{
+		Results: make([]*prompb.QueryResult, 0, len(req.Queries)),
+	}
+	for _, q := range req.Queries {
+		target, err := seriesByTag(q.Matchers)
+		if err != nil {
+			c.JSON(400, err.Error())
+			return
+		}
+		series, status, err := render(c.Req.Request.Context(), c.ID, target, q.StartTimestampMs, q.EndTimestampMs)
+		if err != nil {
+			log.Errorf("prometheus read: query %s failed. %s", target, err)
+			c.JSON(status, err.Error())
+			return
+		}
+		resp.Results = append(resp.Results, &prompb.QueryResult{
+			Timeseries: toPromSeries(series, q.StartTimestampMs, q.EndTimestampMs),
+		})
+	}
+
+	data, err := proto.Marshal(&resp)
+	if err != nil {
+		c.JSON(500, err.Error())
+		return
+	}
+	c.Resp.Header().Set("Content-Type", "application/x-protobuf")
+	c.Resp.Header().Set("Content-Encoding", "snappy")
+	c.Resp.WriteHeader(200)
+	c.Resp.Write(snappy.Encode(nil, data))
+}
+
+// seriesByTag returns the seriesByTag expression selecting the series that
+// match all matchers. Prometheus regular expressions are fully anchored.
+func seriesByTag(matchers []*prompb.LabelMatcher) (string, error) {
+	if len(matchers) == 0 {
+		return "", fmt.Errorf("queries must have at least one matcher")
+	}
+	exprs := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		name := m.Name
+		if name == model.MetricNameLabel {
+			name = "name"
+		}
+		var expr string
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+			expr = name + "=" + m.Value
+		case prompb.LabelMatcher_NEQ:
+			expr = name + "!=" + m.Value
+		case prompb.LabelMatcher_RE:
+			expr = name + "=~^(?:" + m.Value + ")$"
+		case prompb.LabelMatcher_NRE:
+			expr = name + "!=~^(?:" + m.Value + ")$"
+		default:
+			return "", fmt.Errorf("unsupported matcher type %s", m.Type)
+		}
+		// the graphite expression parser doesn't support escaping quotes
+		switch {
+		case !strings.Contains(expr, "'"):
+			exprs = append(exprs, "'"+expr+"'")
+		case !strings.Contains(expr, `"`):
+			exprs = append(exprs, `"`+expr+`"`)
+		default:
+			return "", fmt.Errorf("unsupported matcher %s: can not contain both single and double quotes", expr)
+		}
+	}
+	return "seriesByTag(" + strings.Join(exprs, ",") + ")", nil
+}
+
+// render returns the series of the target between start and end, in ms, as seen
+// by the org. On error, it also returns the status code to respond with.
+func render(ctx context.Context, orgId int, target string, start, end int64) ([]renderSeries, int, error) {
+	from := start / 1000
+	until := end/1000 + 1
+	form := url.Values{
+		"target": {target},
+		"from":   {strconv.FormatInt(from, 10)},
+		"until":  {strconv.FormatInt(until, 10)},
+		"format": {"json"},
+		// there can't be more than a point per second: this avoids consolidation
+		"maxDataPoints": {strconv.FormatInt(until-from+1, 10)},
+	}
+	u := *MetrictankUrl
+	u.Path = util.JoinUrlFragments(MetrictankUrl.Path, "/render")
+	req, err := http.NewRequest("POST", u.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, 500, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Org-Id", strconv.Itoa(orgId))
+
+	res, err := readClient.Do(req)
+	if err != nil {
+		return nil, 502, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 502, err
+	}
+	if res.StatusCode != 200 {
+		return nil, res.StatusCode, fmt.Errorf("metrictank responded with %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var series []renderSeries
+	if err := json.Unmarshal(body, &series); err != nil {
+		return nil, 502, err
+	}
+	return series, 200, nil
+}
+
+// toPromSeries converts the render output to prometheus series, keeping the
+// points between start and end, in ms
+func toPromSeries(series []renderSeries, start, end int64) []*prompb.TimeSeries {
+	result := make([]*prompb.TimeSeries, 0, len(series))
+	for _, s := range series {
+		ts := &prompb.TimeSeries{
+			Labels: make([]prompb.Label, 0, len(s.Tags)),
+		}
+		for name, value := range s.Tags {
+			if name == "name" {
+				name = model.MetricNameLabel
+			}
+			ts.Labels = append(ts.Labels, prompb.Label{Name: name, Value: value})
+		}
+		sort.Slice(ts.Labels, func(i, j int) bool { return ts.Labels[i].Name < ts.Labels[j].Name })
+		for _, point := range s.Datapoints {
+			if point[0] == nil || point[1] == nil || math.IsNaN(*point[0]) {
+				continue
+			}
+			timestamp := int64(*point[1]) * 1000
+			if timestamp < start || timestamp > end {
+				continue
+			}
+			ts.Samples = append(ts.Samples, prompb.Sample{Value: *point[0], Timestamp: timestamp})
+		}
+		result = append(result, ts)
+	}
+	return result
+}
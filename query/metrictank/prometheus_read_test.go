@@ -0,0 +1,83 @@
+package metrictank
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestSeriesByTag(t *testing.T) {
+	tests := []struct {
+		matchers []*prompb.LabelMatcher
+		expected string
+		err      bool
+	}{
+		{
+			matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"}},
+			expected: "seriesByTag('name=up')",
+		},
+		{
+			matchers: []*prompb.LabelMatcher{
+				{Type: prompb.LabelMatcher_RE, Name: "job", Value: "a|b"},
+				{Type: prompb.LabelMatcher_NEQ, Name: "dc", Value: "x"},
+				{Type: prompb.LabelMatcher_NRE, Name: "instance", Value: "it's.*"},
+			},
+			expected: `seriesByTag('job=~^(?:a|b)$','dc!=x',"instance!=~^(?:it's.*)$")`,
+		},
+		{
+			matchers: []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "quote", Value: `'"`}},
+			err:      true,
+		},
+		{
+			err: true,
+		},
+	}
+	for i, tt := range tests {
+		target, err := seriesByTag(tt.matchers)
+		if (err != nil) != tt.err {
+			t.Errorf("case %d: unexpected error %v", i, err)
+			continue
+		}
+		if target != tt.expected {
+			t.Errorf("case %d: expected %s, got %s", i, tt.expected, target)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	var form url.Values
+	var org string
+	mt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		org = r.Header.Get("X-Org-Id")
+		w.Write([]byte(`[{"target":"up;job=a","tags":{"name":"up","job":"a"},"datapoints":[[1,9],[2,10],[null,20],[3,30],[4,31]]}]`))
+	}))
+	defer mt.Close()
+	var err error
+	MetrictankUrl, err = url.Parse(mt.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	series, status, err := render(context.Background(), 3, "seriesByTag('name=up')", 10000, 30500)
+	if err != nil || status != 200 {
+		t.Fatalf("unexpected error %d %v", status, err)
+	}
+	if org != "3" || form.Get("target") != "seriesByTag('name=up')" || form.Get("from") != "10" || form.Get("until") != "31" {
+		t.Fatalf("unexpected request for org %s: %v", org, form)
+	}
+
+	expected := []*prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}},
+		Samples: []prompb.Sample{{Value: 2, Timestamp: 10000}, {Value: 3, Timestamp: 30000}},
+	}}
+	if got := toPromSeries(series, 10000, 30500); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
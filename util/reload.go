@@ -0,0 +1,72 @@
+package util
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadResult is the outcome of reloading one part of the configuration
+type ReloadResult struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+type reloadFunc struct {
+	name string
+	fn   func() error
+}
+
+// Reloader reloads parts of the configuration without restarting.
+// Each reload function must keep the current configuration when it fails.
+type Reloader struct {
+	sync.Mutex
+	funcs []reloadFunc
+}
+
+func NewReloader() *Reloader {
+	return &Reloader{}
+}
+
+// Add registers a function that reloads the named part of the configuration
+func (r *Reloader) Add(name string, fn func() error) {
+	r.Lock()
+	r.funcs = append(r.funcs, reloadFunc{name, fn})
+	r.Unlock()
+}
+
+// Reload runs all reload functions, reporting the outcome of each. It returns
+// false if any of them failed.
+func (r *Reloader) Reload() ([]ReloadResult, bool) {
+	r.Lock()
+	defer r.Unlock()
+	results := make([]ReloadResult, 0, len(r.funcs))
+	ok := true
+	for _, f := range r.funcs {
+		result := ReloadResult{Name: f.name}
+		if err := f.fn(); err != nil {
+			log.Errorf("reload: failed to reload %s, keeping current config: %s", f.name, err)
+			result.Error = err.Error()
+			ok = false
+		} else {
+			log.Infof("reload: reloaded %s", f.name)
+		}
+		results = append(results, result)
+	}
+	return results, ok
+}
+
+// HandleSignals reloads the configuration whenever SIGHUP is received
+func (r *Reloader) HandleSignals() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			log.Info("reload: received SIGHUP")
+			r.Reload()
+		}
+	}()
+}
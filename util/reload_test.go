@@ -0,0 +1,37 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReloader(t *testing.T) {
+	var calls []string
+	r := NewReloader()
+	r.Add("a", func() error {
+		calls = append(calls, "a")
+		return nil
+	})
+	r.Add("b", func() error {
+		calls = append(calls, "b")
+		return errors.New("broken")
+	})
+	r.Add("c", func() error {
+		calls = append(calls, "c")
+		return nil
+	})
+
+	results, ok := r.Reload()
+	if ok {
+		t.Fatalf("expected Reload() to report failure")
+	}
+	// a failing reload must not stop the others
+	if !reflect.DeepEqual(calls, []string{"a", "b", "c"}) {
+		t.Fatalf("expected all reload functions to be called in order, got %v", calls)
+	}
+	expected := []ReloadResult{{Name: "a"}, {Name: "b", Error: "broken"}, {Name: "c"}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("expected results %v, got %v", expected, results)
+	}
+}